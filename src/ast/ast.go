@@ -7,6 +7,7 @@ package ast
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 	"token"
 )
@@ -145,6 +146,15 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+	// Type annotation of a let name or function parameter, nil if none
+	Type *TypeAnnotation `json:",omitempty"`
+
+	// Local, Depth and Slot are set by the resolver. A local is stored at
+	// Slot in the frame Depth frames out from the current one, anything else
+	// is a global or builtin looked up by name
+	Local bool `json:"-"`
+	Depth int  `json:"-"`
+	Slot  int  `json:"-"`
 }
 
 func (i *Identifier) expressionNode() {}
@@ -155,9 +165,35 @@ func (i *Identifier) TokenLiteral() string {
 }
 
 func (i *Identifier) String() string {
+	if i.Type != nil {
+		return i.Value + ": " + i.Type.String()
+	}
 	return i.Value
 }
 
+// TypeAnnotation <name> or <name><<type>, ...>, the type a let name,
+// parameter or function result is declared to have
+type TypeAnnotation struct {
+	Token token.Token
+	Name  string
+	// Params element types, one for array and two for hash, key and value
+	Params []*TypeAnnotation
+}
+
+// TokenLiteral implement Node interface
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string {
+	if len(ta.Params) == 0 {
+		return ta.Name
+	}
+
+	params := make([]string, len(ta.Params))
+	for i, p := range ta.Params {
+		params[i] = p.String()
+	}
+	return ta.Name + "<" + strings.Join(params, ", ") + ">"
+}
+
 // ReturnStatement return <expression>;
 type ReturnStatement struct {
 	Token       token.Token
@@ -245,6 +281,8 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	// Rbrace is the closing brace token
+	Rbrace token.Token
 }
 
 func (bs *BlockStatement) expressionNode() {}
@@ -268,9 +306,17 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral fn <parameters> <block statement>
 // <parameters> => (<parameter one>, <parameter two>, ...)
 type FunctionLiteral struct {
-	Token      token.Token
+	Token token.Token
+	// Name is set by the parser when the literal is bound by a let statement
+	Name       string
 	Parameters []*Identifier
+	// ReturnType annotation after the parameters, nil if none
+	ReturnType *TypeAnnotation `json:",omitempty"`
 	Body       *BlockStatement
+
+	// Locals names of the frame slots of a call, parameters first, set by
+	// the resolver
+	Locals []string `json:"-"`
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -289,6 +335,9 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -299,6 +348,8 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	// Rparen is the closing parenthesis token
+	Rparen token.Token
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -337,6 +388,8 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	// Rbracket is the closing bracket token
+	Rbracket token.Token
 }
 
 func (al *ArrayLiteral) expressionNode() {}
@@ -385,6 +438,10 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Keys lists the keys of Pairs in source order
+	Keys []Expression
+	// Rbrace is the closing brace token
+	Rbrace token.Token
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -395,8 +452,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -405,3 +462,118 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// ThrowStatement throw <expression>;
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// TokenLiteral implement Node interface
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// TryExpression try <block statement> [catch (<identifier>) <block statement>] [finally <block statement>]
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+
+	// CatchLocals names of the frame slots of the catch block, the parameter
+	// first, set by the resolver
+	CatchLocals []string `json:"-"`
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// TokenLiteral implement Node interface
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.Param.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// ImportExpression import "<path>"
+type ImportExpression struct {
+	Token token.Token
+	Path  string
+}
+
+func (ie *ImportExpression) expressionNode() {}
+
+// TokenLiteral implement Node interface
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " \"" + ie.Path + "\""
+}
+
+// MemberExpression <expression>.<identifier>
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+
+// TokenLiteral implement Node interface
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// OrderedKeys return the keys of Pairs in source order, or sorted by their
+// String form when the literal was built without Keys
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	return keys
+}
@@ -34,3 +34,21 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestTypeAnnotationString(t *testing.T) {
+	x := &Identifier{Value: "x", Type: &TypeAnnotation{
+		Name:   "hash",
+		Params: []*TypeAnnotation{{Name: "string"}, {Name: "array", Params: []*TypeAnnotation{{Name: "int"}}}},
+	}}
+	fn := &FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+		Parameters: []*Identifier{x, {Value: "y"}},
+		ReturnType: &TypeAnnotation{Name: "bool"},
+		Body:       &BlockStatement{},
+	}
+
+	expected := "fn(x: hash<string, array<int>>, y) -> bool {  }"
+	if fn.String() != expected {
+		t.Errorf("fn.String() wrong. expected=%q, got=%q", expected, fn.String())
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import "fmt"
+
+// Clone return a deep copy of node, sharing nothing with it. Clone panics on
+// node types it does not know
+func Clone(node Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *Program:
+		return &Program{Statements: cloneStatements(n.Statements)}
+	case *LetStatement:
+		return &LetStatement{Token: n.Token, Name: cloneIdentifier(n.Name), Value: cloneExpression(n.Value)}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: cloneExpression(n.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: cloneExpression(n.Expression)}
+	case *BlockStatement:
+		return &BlockStatement{Token: n.Token, Statements: cloneStatements(n.Statements), Rbrace: n.Rbrace}
+	case *ThrowStatement:
+		return &ThrowStatement{Token: n.Token, Value: cloneExpression(n.Value)}
+	case *Identifier:
+		c := *n
+		c.Type = cloneAnnotation(n.Type)
+		return &c
+	case *TypeAnnotation:
+		c := &TypeAnnotation{Token: n.Token, Name: n.Name}
+		if n.Params != nil {
+			c.Params = make([]*TypeAnnotation, len(n.Params))
+			for i, param := range n.Params {
+				c.Params[i] = cloneAnnotation(param)
+			}
+		}
+		return c
+	case *IntegerLiteral:
+		c := *n
+		return &c
+	case *StringLiteral:
+		c := *n
+		return &c
+	case *Boolean:
+		c := *n
+		return &c
+	case *ImportExpression:
+		c := *n
+		return &c
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: cloneExpression(n.Right)}
+	case *InfixExpression:
+		return &InfixExpression{Token: n.Token, Left: cloneExpression(n.Left), Operator: n.Operator, Right: cloneExpression(n.Right)}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       n.Token,
+			Condition:   cloneExpression(n.Condition),
+			Consequence: cloneBlock(n.Consequence),
+			Alternative: cloneBlock(n.Alternative),
+		}
+	case *FunctionLiteral:
+		var params []*Identifier
+		if n.Parameters != nil {
+			params = make([]*Identifier, len(n.Parameters))
+			for i, param := range n.Parameters {
+				params[i] = cloneIdentifier(param)
+			}
+		}
+		return &FunctionLiteral{
+			Token:      n.Token,
+			Name:       n.Name,
+			Parameters: params,
+			ReturnType: cloneAnnotation(n.ReturnType),
+			Body:       cloneBlock(n.Body),
+			Locals:     cloneStrings(n.Locals),
+		}
+	case *CallExpression:
+		return &CallExpression{Token: n.Token, Function: cloneExpression(n.Function), Arguments: cloneExpressions(n.Arguments)}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: n.Token, Elements: cloneExpressions(n.Elements)}
+	case *IndexExpression:
+		return &IndexExpression{Token: n.Token, Left: cloneExpression(n.Left), Index: cloneExpression(n.Index)}
+	case *HashLiteral:
+		c := &HashLiteral{Token: n.Token, Pairs: make(map[Expression]Expression, len(n.Pairs))}
+		for _, key := range n.OrderedKeys() {
+			k := cloneExpression(key)
+			c.Pairs[k] = cloneExpression(n.Pairs[key])
+			c.Keys = append(c.Keys, k)
+		}
+		return c
+	case *TryExpression:
+		return &TryExpression{
+			Token:       n.Token,
+			Block:       cloneBlock(n.Block),
+			Param:       cloneIdentifier(n.Param),
+			Catch:       cloneBlock(n.Catch),
+			Finally:     cloneBlock(n.Finally),
+			CatchLocals: cloneStrings(n.CatchLocals),
+		}
+	case *MemberExpression:
+		return &MemberExpression{Token: n.Token, Object: cloneExpression(n.Object), Property: cloneIdentifier(n.Property)}
+	default:
+		panic(fmt.Sprintf("ast.Clone: unexpected node type %T", n))
+	}
+}
+
+func cloneExpression(expr Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+	return Clone(expr).(Expression)
+}
+
+func cloneExpressions(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+
+	c := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		c[i] = cloneExpression(expr)
+	}
+	return c
+}
+
+func cloneStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	c := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		if stmt != nil {
+			c[i] = Clone(stmt).(Statement)
+		}
+	}
+	return c
+}
+
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return Clone(ident).(*Identifier)
+}
+
+func cloneAnnotation(ta *TypeAnnotation) *TypeAnnotation {
+	if ta == nil {
+		return nil
+	}
+	return Clone(ta).(*TypeAnnotation)
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return Clone(block).(*BlockStatement)
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import "testing"
+
+func TestClone(t *testing.T) {
+	program := sampleProgram()
+	clone := Clone(program).(*Program)
+
+	if !Equal(program, clone) {
+		t.Fatalf("clone not equal. expected=%s, got=%s", program, clone)
+	}
+
+	before, _ := EncodeJSON(program)
+	cloned, _ := EncodeJSON(clone)
+	if string(before) != string(cloned) {
+		t.Errorf("clone lost fields.\nexpected=%s\ngot=%s", before, cloned)
+	}
+
+	// changing the clone leaves the original alone
+	Modify(clone, turnOneIntoTwo)
+	clone.Statements[0].(*LetStatement).Name.Value = "g"
+
+	after, _ := EncodeJSON(program)
+	if string(before) != string(after) {
+		t.Errorf("original changed through its clone.\nbefore=%s\nafter=%s", before, after)
+	}
+
+	shared := map[Node]bool{}
+	Inspect(program, func(node Node) bool {
+		shared[node] = true
+		return true
+	})
+	Inspect(clone, func(node Node) bool {
+		if shared[node] {
+			t.Errorf("clone shares node %s", node)
+		}
+		return true
+	})
+}
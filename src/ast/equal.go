@@ -0,0 +1,157 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import "fmt"
+
+// Equal report whether a and b are the same tree: nodes of the same types
+// with the same names, values and operators, and equal children in the same
+// order. Tokens are not compared, so the same program parsed from
+// differently laid out source is Equal. Equal panics on node types it does
+// not know
+func Equal(a, b Node) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && equalStatements(a.Statements, b.Statements)
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.ReturnValue, b.ReturnValue)
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && equalStatements(a.Statements, b.Statements)
+	case *ThrowStatement:
+		b, ok := b.(*ThrowStatement)
+		return ok && Equal(a.Value, b.Value)
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value && Equal(a.Type, b.Type)
+	case *TypeAnnotation:
+		b, ok := b.(*TypeAnnotation)
+		if !ok || a.Name != b.Name || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !Equal(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return true
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *ImportExpression:
+		b, ok := b.(*ImportExpression)
+		return ok && a.Path == b.Path
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) &&
+			Equal(a.Consequence, b.Consequence) && Equal(a.Alternative, b.Alternative)
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		if !ok || a.Name != b.Name || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !Equal(a.Parameters[i], b.Parameters[i]) {
+				return false
+			}
+		}
+		return Equal(a.ReturnType, b.ReturnType) && Equal(a.Body, b.Body)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		return ok && Equal(a.Function, b.Function) && equalExpressions(a.Arguments, b.Arguments)
+	case *ArrayLiteral:
+		b, ok := b.(*ArrayLiteral)
+		return ok && equalExpressions(a.Elements, b.Elements)
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+	case *HashLiteral:
+		b, ok := b.(*HashLiteral)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		aKeys, bKeys := a.OrderedKeys(), b.OrderedKeys()
+		for i := range aKeys {
+			if !Equal(aKeys[i], bKeys[i]) || !Equal(a.Pairs[aKeys[i]], b.Pairs[bKeys[i]]) {
+				return false
+			}
+		}
+		return true
+	case *TryExpression:
+		b, ok := b.(*TryExpression)
+		return ok && Equal(a.Block, b.Block) && Equal(a.Param, b.Param) &&
+			Equal(a.Catch, b.Catch) && Equal(a.Finally, b.Finally)
+	case *MemberExpression:
+		b, ok := b.(*MemberExpression)
+		return ok && Equal(a.Object, b.Object) && Equal(a.Property, b.Property)
+	default:
+		panic(fmt.Sprintf("ast.Equal: unexpected node type %T", a))
+	}
+}
+
+// isNil report whether node is nil, or a nil pointer such as a missing
+// *BlockStatement passed as a Node
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *Identifier:
+		return n == nil
+	case *TypeAnnotation:
+		return n == nil
+	case *BlockStatement:
+		return n == nil
+	}
+	return false
+}
+
+func equalStatements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalExpressions(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
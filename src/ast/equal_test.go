@@ -0,0 +1,98 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import (
+	"testing"
+	"token"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b     Node
+		expected bool
+	}{
+		{sampleProgram(), sampleProgram(), true},
+		{integer(1), integer(1), true},
+		{integer(1), integer(2), false},
+		{integer(1), &StringLiteral{Value: "1"}, false},
+		// tokens and positions do not matter
+		{ident("x", 1, 1), ident("x", 7, 3), true},
+		{
+			&IntegerLiteral{Token: token.Token{Literal: "010"}, Value: 8},
+			&IntegerLiteral{Token: token.Token{Literal: "8"}, Value: 8},
+			true,
+		},
+		{
+			&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+			&InfixExpression{Left: integer(1), Operator: "-", Right: integer(2)},
+			false,
+		},
+		{
+			&IfExpression{Condition: integer(1), Consequence: block()},
+			&IfExpression{Condition: integer(1), Consequence: block(), Alternative: block()},
+			false,
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{ident("a", 0, 0)}, Body: block()},
+			&FunctionLiteral{Parameters: []*Identifier{ident("b", 0, 0)}, Body: block()},
+			false,
+		},
+		{
+			&FunctionLiteral{Name: "f", Body: block()},
+			&FunctionLiteral{Body: block()},
+			false,
+		},
+		{
+			&CallExpression{Function: ident("f", 0, 0), Arguments: []Expression{integer(1)}},
+			&CallExpression{Function: ident("f", 0, 0)},
+			false,
+		},
+		{&Program{}, &Program{Statements: []Statement{}}, true},
+		{block(exprStmt(integer(1))), block(&ReturnStatement{ReturnValue: integer(1)}), false},
+		{&TryExpression{Param: ident("e", 0, 0)}, &TryExpression{}, false},
+		{&ImportExpression{Path: "a"}, &ImportExpression{Path: "b"}, false},
+		{annotation("array", annotation("int")), annotation("array", annotation("int")), true},
+		{annotation("array", annotation("int")), annotation("array"), false},
+		{&Identifier{Value: "x", Type: annotation("int")}, &Identifier{Value: "x"}, false},
+		{
+			&FunctionLiteral{ReturnType: annotation("int"), Body: block()},
+			&FunctionLiteral{ReturnType: annotation("bool"), Body: block()},
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Equal(%s, %s) wrong. expected=%t, got=%t", i, tt.a, tt.b, tt.expected, got)
+		}
+		if got := Equal(tt.b, tt.a); got != tt.expected {
+			t.Errorf("tests[%d] - Equal is not symmetric", i)
+		}
+	}
+}
+
+func TestEqualHashLiteralOrder(t *testing.T) {
+	hash := func(keys ...int64) *HashLiteral {
+		hl := &HashLiteral{Pairs: map[Expression]Expression{}}
+		for _, k := range keys {
+			key := integer(k)
+			hl.Pairs[key] = integer(k * 10)
+			hl.Keys = append(hl.Keys, key)
+		}
+		return hl
+	}
+
+	if !Equal(hash(1, 2), hash(1, 2)) {
+		t.Errorf("equal hash literals reported different")
+	}
+	if Equal(hash(1, 2), hash(2, 1)) {
+		t.Errorf("hash literals in different order reported equal")
+	}
+	if Equal(hash(1, 2), hash(1)) {
+		t.Errorf("hash literals of different sizes reported equal")
+	}
+}
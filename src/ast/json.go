@@ -0,0 +1,342 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"token"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSON form of the tree: every node is an object whose "kind" is the name of
+// its type, followed by its fields in declaration order, named as in Go with
+// the first letter lowered. Tokens are objects with type, literal, line and
+// column. Missing children are null, except type annotations, which are left
+// out so that unannotated code encodes as it did before annotations. Hash
+// literals have a "pairs" array of {"key", "value"} objects in source order
+// instead of Pairs and Keys. Fields tagged json:"-", which the resolver
+// derives from the tree, are left out.
+//
+//	{"kind":"Identifier","token":{"type":"IDENT","literal":"x","line":1,"column":5},"value":"x"}
+
+// nodeTypes node kinds by name, the types in this package that can be encoded
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []Node{
+		&Program{},
+		&LetStatement{},
+		&ReturnStatement{},
+		&ExpressionStatement{},
+		&BlockStatement{},
+		&ThrowStatement{},
+		&Identifier{},
+		&TypeAnnotation{},
+		&IntegerLiteral{},
+		&StringLiteral{},
+		&Boolean{},
+		&PrefixExpression{},
+		&InfixExpression{},
+		&IfExpression{},
+		&FunctionLiteral{},
+		&CallExpression{},
+		&ArrayLiteral{},
+		&IndexExpression{},
+		&HashLiteral{},
+		&TryExpression{},
+		&ImportExpression{},
+		&MemberExpression{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+var (
+	tokenType = reflect.TypeOf(token.Token{})
+	hashType  = reflect.TypeOf(HashLiteral{})
+)
+
+type jsonToken struct {
+	Type    token.Type `json:"type"`
+	Literal string     `json:"literal"`
+	Line    int        `json:"line"`
+	Column  int        `json:"column"`
+}
+
+type jsonPair struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// EncodeJSON encode node and all of its children as JSON
+func EncodeJSON(node Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, reflect.ValueOf(node)); err != nil {
+		return nil, fmt.Errorf("ast: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeJSON decode a node encoded by EncodeJSON
+func DecodeJSON(data []byte) (Node, error) {
+	v, err := decodeNode(data)
+	if err != nil {
+		return nil, fmt.Errorf("ast: %s", err)
+	}
+	if !v.IsValid() {
+		return nil, fmt.Errorf("ast: cannot decode null as a node")
+	}
+	return v.Interface().(Node), nil
+}
+
+func skipField(f reflect.StructField) bool {
+	return f.Tag.Get("json") == "-"
+}
+
+// omitField report whether field f, of value v, is tagged to be left out
+// when nil
+func omitField(f reflect.StructField, v reflect.Value) bool {
+	return f.Tag.Get("json") == ",omitempty" && v.IsNil()
+}
+
+// jsonName field name in JSON, the Go name with the first letter lowered
+func jsonName(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[n:]
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Type() {
+	case tokenType:
+		tok := v.Interface().(token.Token)
+		return encodeJSON(buf, jsonToken{tok.Type, tok.Literal, tok.Line, tok.Column})
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return encodeValue(buf, v.Elem())
+		}
+		return encodeNode(buf, v)
+	case reflect.Slice:
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i != 0 {
+				buf.WriteString(",")
+			}
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+		return nil
+	case reflect.String, reflect.Int64, reflect.Bool:
+		return encodeJSON(buf, v.Interface())
+	default:
+		return fmt.Errorf("cannot encode value of type %s", v.Type())
+	}
+}
+
+func encodeJSON(buf *bytes.Buffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// encodeNode encode v, a pointer to a node
+func encodeNode(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Type().Elem()
+	if nodeTypes[t.Name()] != t {
+		return fmt.Errorf("cannot encode node of type %s", v.Type())
+	}
+
+	buf.WriteString(`{"kind":`)
+	encodeJSON(buf, t.Name())
+
+	if t == hashType {
+		return encodeHash(buf, v.Interface().(*HashLiteral))
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if skipField(t.Field(i)) || omitField(t.Field(i), v.Elem().Field(i)) {
+			continue
+		}
+		buf.WriteString(",")
+		encodeJSON(buf, jsonName(t.Field(i).Name))
+		buf.WriteString(":")
+		if err := encodeValue(buf, v.Elem().Field(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+func encodeHash(buf *bytes.Buffer, hl *HashLiteral) error {
+	buf.WriteString(`,"token":`)
+	encodeValue(buf, reflect.ValueOf(hl.Token))
+
+	buf.WriteString(`,"pairs":[`)
+	for i, key := range hl.OrderedKeys() {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(`{"key":`)
+		if err := encodeValue(buf, reflect.ValueOf(key)); err != nil {
+			return err
+		}
+		buf.WriteString(`,"value":`)
+		if err := encodeValue(buf, reflect.ValueOf(hl.Pairs[key])); err != nil {
+			return err
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]}")
+	return nil
+}
+
+func isNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
+
+// decodeNode decode a node object into a pointer to its type. null gives the
+// zero reflect.Value
+func decodeNode(data []byte) (reflect.Value, error) {
+	if isNull(data) {
+		return reflect.Value{}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return reflect.Value{}, fmt.Errorf("node has no kind")
+	}
+	t, ok := nodeTypes[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node kind %q", kind)
+	}
+	delete(fields, "kind")
+
+	v := reflect.New(t)
+	if t == hashType {
+		return v, decodeHash(v.Interface().(*HashLiteral), fields)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if skipField(t.Field(i)) {
+			continue
+		}
+		name := jsonName(t.Field(i).Name)
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		delete(fields, name)
+
+		if err := decodeValue(v.Elem().Field(i), raw); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %s", kind, name, err)
+		}
+	}
+	for name := range fields {
+		return reflect.Value{}, fmt.Errorf("unknown field %q in %s", name, kind)
+	}
+
+	return v, nil
+}
+
+func decodeHash(hl *HashLiteral, fields map[string]json.RawMessage) error {
+	if raw, ok := fields["token"]; ok {
+		if err := decodeValue(reflect.ValueOf(&hl.Token).Elem(), raw); err != nil {
+			return fmt.Errorf("HashLiteral.token: %s", err)
+		}
+	}
+
+	var pairs []jsonPair
+	if raw, ok := fields["pairs"]; ok {
+		if err := json.Unmarshal(raw, &pairs); err != nil {
+			return fmt.Errorf("HashLiteral.pairs: %s", err)
+		}
+	}
+
+	hl.Pairs = make(map[Expression]Expression, len(pairs))
+	for i, pair := range pairs {
+		var key, value Expression
+		if err := decodeValue(reflect.ValueOf(&key).Elem(), pair.Key); err != nil {
+			return fmt.Errorf("HashLiteral.pairs[%d].key: %s", i, err)
+		}
+		if err := decodeValue(reflect.ValueOf(&value).Elem(), pair.Value); err != nil {
+			return fmt.Errorf("HashLiteral.pairs[%d].value: %s", i, err)
+		}
+		hl.Pairs[key] = value
+		hl.Keys = append(hl.Keys, key)
+	}
+
+	for name := range fields {
+		if name != "token" && name != "pairs" {
+			return fmt.Errorf("unknown field %q in HashLiteral", name)
+		}
+	}
+	return nil
+}
+
+// decodeValue decode data into the settable value v
+func decodeValue(v reflect.Value, data []byte) error {
+	if v.Type() == tokenType {
+		var tok jsonToken
+		if err := json.Unmarshal(data, &tok); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(token.Token{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		node, err := decodeNode(data)
+		if err != nil || !node.IsValid() {
+			return err
+		}
+		if !node.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("%s cannot be used as %s", node.Type().Elem().Name(), v.Type())
+		}
+		v.Set(node)
+		return nil
+	case reflect.Slice:
+		if isNull(data) {
+			return nil
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := decodeValue(s.Index(i), elem); err != nil {
+				return fmt.Errorf("[%d]: %s", i, err)
+			}
+		}
+		v.Set(s)
+		return nil
+	default:
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import (
+	"strings"
+	"testing"
+	"token"
+)
+
+func ident(name string, line, column int) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Line: line, Column: column}, Value: name}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	key := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: "b"}, Value: "b"}
+	other := &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+				Name:  ident("f", 1, 5),
+				Value: &FunctionLiteral{
+					Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Line: 1, Column: 9},
+					Name:       "f",
+					Parameters: []*Identifier{ident("x", 1, 12)},
+					ReturnType: &TypeAnnotation{
+						Token:  token.Token{Type: token.IDENT, Literal: "array", Line: 1, Column: 18},
+						Name:   "array",
+						Params: []*TypeAnnotation{{Token: token.Token{Type: token.IDENT, Literal: "int"}, Name: "int"}},
+					},
+					Body: &BlockStatement{
+						Token: token.Token{Type: token.LBRACE, Literal: "{"},
+						Statements: []Statement{
+							&ReturnStatement{
+								Token: token.Token{Type: token.RETURN, Literal: "return"},
+								ReturnValue: &InfixExpression{
+									Token:    token.Token{Type: token.PLUS, Literal: "+"},
+									Left:     ident("x", 1, 23),
+									Operator: "+",
+									Right:    &PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-"}, Operator: "-", Right: other},
+								},
+							},
+						},
+						Rbrace: token.Token{Type: token.RBRACE, Literal: "}"},
+					},
+				},
+			},
+			&ExpressionStatement{
+				Token: token.Token{Type: token.IF, Literal: "if"},
+				Expression: &IfExpression{
+					Token:       token.Token{Type: token.IF, Literal: "if"},
+					Condition:   &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+					Consequence: &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: []Statement{}},
+				},
+			},
+			&ExpressionStatement{
+				Token: token.Token{Type: token.LBRACE, Literal: "{"},
+				Expression: &HashLiteral{
+					Token: token.Token{Type: token.LBRACE, Literal: "{"},
+					Pairs: map[Expression]Expression{key: &ArrayLiteral{Elements: []Expression{}}, other: key},
+					Keys:  []Expression{key, other},
+				},
+			},
+			&ThrowStatement{Value: &MemberExpression{Object: &ImportExpression{Path: "lib"}, Property: ident("y", 0, 0)}},
+		},
+	}
+
+	data, err := EncodeJSON(program)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+
+	node, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON returned error: %s", err)
+	}
+	decoded, ok := node.(*Program)
+	if !ok {
+		t.Fatalf("decoded node is not *Program. got=%T", node)
+	}
+
+	if decoded.String() != program.String() {
+		t.Errorf("decoded program wrong. expected=%q, got=%q", program.String(), decoded.String())
+	}
+
+	again, err := EncodeJSON(decoded)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip is lossy.\nfirst=%s\nsecond=%s", data, again)
+	}
+
+	fn := decoded.Statements[0].(*LetStatement).Value.(*FunctionLiteral)
+	if fn.Name != "f" || fn.Parameters[0].Token.Column != 12 || fn.ReturnType.String() != "array<int>" {
+		t.Errorf("function literal fields lost. got=%+v", fn)
+	}
+	hash := decoded.Statements[2].(*ExpressionStatement).Expression.(*HashLiteral)
+	if len(hash.Keys) != 2 || hash.Keys[0].String() != "b" || hash.Pairs[hash.Keys[1]] == nil {
+		t.Errorf("hash literal pairs lost. got=%+v", hash)
+	}
+	ifExp := decoded.Statements[1].(*ExpressionStatement).Expression.(*IfExpression)
+	if ifExp.Alternative != nil {
+		t.Errorf("null alternative decoded as %+v", ifExp.Alternative)
+	}
+}
+
+func TestEncodeJSONFormat(t *testing.T) {
+	data, err := EncodeJSON(ident("x", 1, 5))
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+
+	expected := `{"kind":"Identifier","token":{"type":"IDENT","literal":"x","line":1,"column":5},"value":"x"}`
+	if string(data) != expected {
+		t.Errorf("EncodeJSON wrong. expected=%q, got=%q", expected, data)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`null`, "cannot decode null as a node"},
+		{`{"value": "x"}`, "node has no kind"},
+		{`{"kind": "Loop"}`, `unknown node kind "Loop"`},
+		{`{"kind": "Identifier", "name": "x"}`, `unknown field "name" in Identifier`},
+		{`{"kind": "LetStatement", "name": {"kind": "Boolean"}}`, "LetStatement.name: Boolean cannot be used as *ast.Identifier"},
+		{`{"kind": "Program", "statements": [{"kind": "Identifier"}]}`, "Program.statements: [0]: Identifier cannot be used as ast.Statement"},
+		{`{"kind": "IntegerLiteral", "value": "1"}`, "IntegerLiteral.value"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("DecodeJSON(%s) returned no error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("DecodeJSON(%s) error wrong. expected to contain %q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
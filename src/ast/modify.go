@@ -0,0 +1,171 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import "fmt"
+
+// ModifierFunc return the node to put in place of node, which may be node
+// itself
+type ModifierFunc func(node Node) Node
+
+// Modify rewrite the tree rooted at node bottom-up: the children of each node
+// are modified first, then the node is passed to modifier and replaced by
+// what it returns. Returning nil for a statement removes it from its program
+// or block. Hash literal pairs are rebuilt from their modified keys and
+// values. Modify changes nodes in place and returns the new root. It panics
+// on node types it does not know, and when a node is replaced with one that
+// does not fit where it is, such as a function parameter that is no longer
+// an *Identifier
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *ThrowStatement:
+		n.Value = modifyExpression(n.Value, modifier)
+	case *Identifier:
+		n.Type = modifyAnnotation(n.Type, modifier)
+	case *TypeAnnotation:
+		for i, param := range n.Params {
+			n.Params[i] = modifyAnnotation(param, modifier)
+		}
+	case *IntegerLiteral, *StringLiteral, *Boolean, *ImportExpression:
+		// leaves
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		n.ReturnType = modifyAnnotation(n.ReturnType, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *HashLiteral:
+		keys := n.OrderedKeys()
+		pairs := make(map[Expression]Expression, len(keys))
+		newKeys := make([]Expression, 0, len(keys))
+		for _, key := range keys {
+			value := modifyExpression(n.Pairs[key], modifier)
+			key = modifyExpression(key, modifier)
+			pairs[key] = value
+			newKeys = append(newKeys, key)
+		}
+		n.Pairs, n.Keys = pairs, newKeys
+	case *TryExpression:
+		n.Block = modifyBlock(n.Block, modifier)
+		n.Param = modifyIdentifier(n.Param, modifier)
+		n.Catch = modifyBlock(n.Catch, modifier)
+		n.Finally = modifyBlock(n.Finally, modifier)
+	case *MemberExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Property = modifyIdentifier(n.Property, modifier)
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	out := stmts[:0]
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+
+		switch modified := Modify(stmt, modifier).(type) {
+		case nil:
+			// removed
+		case Statement:
+			out = append(out, modified)
+		default:
+			panic(fmt.Sprintf("ast.Modify: %T replaced with %T, which is not a statement", stmt, modified))
+		}
+	}
+	return out
+}
+
+func modifyExpression(expr Expression, modifier ModifierFunc) Expression {
+	if expr == nil {
+		return nil
+	}
+
+	switch modified := Modify(expr, modifier).(type) {
+	case nil:
+		return nil
+	case Expression:
+		return modified
+	default:
+		panic(fmt.Sprintf("ast.Modify: %T replaced with %T, which is not an expression", expr, modified))
+	}
+}
+
+func modifyExpressions(exprs []Expression, modifier ModifierFunc) {
+	for i, expr := range exprs {
+		exprs[i] = modifyExpression(expr, modifier)
+	}
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+
+	result := Modify(ident, modifier)
+	modified, ok := result.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: *ast.Identifier replaced with %T", result))
+	}
+	return modified
+}
+
+func modifyAnnotation(ta *TypeAnnotation, modifier ModifierFunc) *TypeAnnotation {
+	if ta == nil {
+		return nil
+	}
+
+	result := Modify(ta, modifier)
+	modified, ok := result.(*TypeAnnotation)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: *ast.TypeAnnotation replaced with %T", result))
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	result := Modify(block, modifier)
+	modified, ok := result.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: *ast.BlockStatement replaced with %T", result))
+	}
+	return modified
+}
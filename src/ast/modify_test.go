@@ -0,0 +1,154 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// turnOneIntoTwo replace integer literals 1 with 2
+func turnOneIntoTwo(node Node) Node {
+	integer, ok := node.(*IntegerLiteral)
+	if !ok || integer.Value != 1 {
+		return node
+	}
+
+	integer.Value = 2
+	integer.Token.Literal = "2"
+	return integer
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{exprStmt(one())}},
+			&Program{Statements: []Statement{exprStmt(two())}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{Condition: one(), Consequence: block(exprStmt(one())), Alternative: block(exprStmt(one()))},
+			&IfExpression{Condition: two(), Consequence: block(exprStmt(two())), Alternative: block(exprStmt(two()))},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: ident("x", 0, 0), Value: one()},
+			&LetStatement{Name: ident("x", 0, 0), Value: two()},
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(one()))},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(two()))},
+		},
+		{
+			&CallExpression{Function: ident("f", 0, 0), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: ident("f", 0, 0), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&TryExpression{Block: block(exprStmt(one())), Param: ident("e", 0, 0), Catch: block(exprStmt(one()))},
+			&TryExpression{Block: block(exprStmt(two())), Param: ident("e", 0, 0), Catch: block(exprStmt(two()))},
+		},
+		{
+			&MemberExpression{Object: one(), Property: ident("p", 0, 0)},
+			&MemberExpression{Object: two(), Property: ident("p", 0, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if !Equal(modified, tt.expected) {
+			t.Errorf("not equal. expected=%s, got=%s", tt.expected, modified)
+		}
+	}
+}
+
+func TestModifyHashLiteral(t *testing.T) {
+	keys := []Expression{integer(1), integer(1), integer(3)}
+	hash := &HashLiteral{
+		Pairs: map[Expression]Expression{keys[0]: integer(1), keys[1]: integer(2), keys[2]: integer(1)},
+		Keys:  keys,
+	}
+
+	Modify(hash, turnOneIntoTwo)
+
+	if len(hash.Pairs) != 3 || len(hash.Keys) != 3 {
+		t.Fatalf("wrong number of pairs. got=%d pairs, %d keys", len(hash.Pairs), len(hash.Keys))
+	}
+	expected := [][2]int64{{2, 2}, {2, 2}, {3, 2}}
+	for i, key := range hash.Keys {
+		value := hash.Pairs[key]
+		if key.(*IntegerLiteral).Value != expected[i][0] || value.(*IntegerLiteral).Value != expected[i][1] {
+			t.Errorf("pair %d wrong. expected=%v, got=%s: %s", i, expected[i], key, value)
+		}
+	}
+}
+
+func TestModifyRemovesStatements(t *testing.T) {
+	program := sampleProgram()
+
+	Modify(program, func(node Node) Node {
+		if _, ok := node.(*ThrowStatement); ok {
+			return nil
+		}
+		if let, ok := node.(*LetStatement); ok && let.Name.Value == "h" {
+			return nil
+		}
+		return node
+	})
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	alt := program.Statements[1].(*ExpressionStatement).Expression.(*IfExpression).Alternative
+	if len(alt.Statements) != 0 {
+		t.Errorf("throw statement not removed. got=%s", alt)
+	}
+}
+
+func TestModifyPanicsOnMisfit(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "*ast.Identifier replaced with *ast.IntegerLiteral") {
+			t.Errorf("wrong panic. got=%v", r)
+		}
+	}()
+
+	Modify(&LetStatement{Name: ident("x", 0, 0), Value: integer(1)}, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return integer(1)
+		}
+		return node
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import "fmt"
+
+// Visitor Visit is called for each node met by Walk. If the visitor w it
+// returns is not nil, Walk visits the children of node with w, then calls
+// w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverse the tree rooted at node in depth-first order, children in
+// source order. Nil children are skipped. Walk panics on node types it does
+// not know
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *ThrowStatement:
+		walkExpression(v, n.Value)
+	case *Identifier:
+		walkAnnotation(v, n.Type)
+	case *TypeAnnotation:
+		for _, param := range n.Params {
+			walkAnnotation(v, param)
+		}
+	case *IntegerLiteral, *StringLiteral, *Boolean, *ImportExpression:
+		// leaves
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			walkIdentifier(v, param)
+		}
+		walkAnnotation(v, n.ReturnType)
+		walkBlock(v, n.Body)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *HashLiteral:
+		for _, key := range n.OrderedKeys() {
+			walkExpression(v, key)
+			walkExpression(v, n.Pairs[key])
+		}
+	case *TryExpression:
+		walkBlock(v, n.Block)
+		walkIdentifier(v, n.Param)
+		walkBlock(v, n.Catch)
+		walkBlock(v, n.Finally)
+	case *MemberExpression:
+		walkExpression(v, n.Object)
+		walkIdentifier(v, n.Property)
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// the typed nil checks keep a nil *Identifier, *TypeAnnotation or
+// *BlockStatement from being visited as a non-nil Node
+
+func walkIdentifier(v Visitor, ident *Identifier) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkAnnotation(v Visitor, ta *TypeAnnotation) {
+	if ta != nil {
+		Walk(v, ta)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+func walkExpression(v Visitor, expr Expression) {
+	if expr != nil {
+		Walk(v, expr)
+	}
+}
+
+func walkExpressions(v Visitor, exprs []Expression) {
+	for _, expr := range exprs {
+		walkExpression(v, expr)
+	}
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverse the tree rooted at node like Walk, calling f for each
+// node. The children of a node are skipped when f returns false
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package ast
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	monkeytoken "token"
+)
+
+func tok(t monkeytoken.Type, literal string) monkeytoken.Token {
+	return monkeytoken.Token{Type: t, Literal: literal}
+}
+
+func integer(v int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: tok(monkeytoken.INT, fmt.Sprint(v)), Value: v}
+}
+
+func block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Token: tok(monkeytoken.LBRACE, "{"), Statements: stmts}
+}
+
+func annotation(name string, params ...*TypeAnnotation) *TypeAnnotation {
+	return &TypeAnnotation{Token: tok(monkeytoken.IDENT, name), Name: name, Params: params}
+}
+
+func exprStmt(expr Expression) *ExpressionStatement {
+	return &ExpressionStatement{Expression: expr}
+}
+
+// sampleProgram build a program with a node of every type:
+//
+//	let f = fn(x: array<int>) -> int { return -x + 1; };
+//	if (true) { f(2)[0] } else { throw "no"; };
+//	let h = {"k": [3], 4: import "lib".v};
+//	try { h } catch (e) { e } finally { 5 }
+func sampleProgram() *Program {
+	key := &StringLiteral{Token: tok(monkeytoken.STRING, "k"), Value: "k"}
+	four := integer(4)
+
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Token: tok(monkeytoken.LET, "let"),
+			Name:  ident("f", 1, 5),
+			Value: &FunctionLiteral{
+				Name: "f",
+				Parameters: []*Identifier{{
+					Token: tok(monkeytoken.IDENT, "x"),
+					Value: "x",
+					Type:  annotation("array", annotation("int")),
+				}},
+				ReturnType: annotation("int"),
+				Body: block(&ReturnStatement{ReturnValue: &InfixExpression{
+					Left:     &PrefixExpression{Operator: "-", Right: ident("x", 1, 24)},
+					Operator: "+",
+					Right:    integer(1),
+				}}),
+			},
+		},
+		exprStmt(&IfExpression{
+			Condition: &Boolean{Token: tok(monkeytoken.TRUE, "true"), Value: true},
+			Consequence: block(exprStmt(&IndexExpression{
+				Left:  &CallExpression{Function: ident("f", 2, 13), Arguments: []Expression{integer(2)}},
+				Index: integer(0),
+			})),
+			Alternative: block(&ThrowStatement{Value: &StringLiteral{Value: "no"}}),
+		}),
+		&LetStatement{
+			Name: ident("h", 3, 5),
+			Value: &HashLiteral{
+				Pairs: map[Expression]Expression{
+					key:  &ArrayLiteral{Elements: []Expression{integer(3)}},
+					four: &MemberExpression{Object: &ImportExpression{Path: "lib"}, Property: ident("v", 3, 32)},
+				},
+				Keys: []Expression{key, four},
+			},
+		},
+		exprStmt(&TryExpression{
+			Block:   block(exprStmt(ident("h", 4, 7))),
+			Param:   ident("e", 4, 18),
+			Catch:   block(exprStmt(ident("e", 4, 23))),
+			Finally: block(exprStmt(integer(5))),
+		}),
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var visited []string
+	Inspect(sampleProgram(), func(node Node) bool {
+		name := reflect.TypeOf(node).Elem().Name()
+		if ident, ok := node.(*Identifier); ok {
+			name += " " + ident.Value
+		}
+		visited = append(visited, name)
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier f",
+		"FunctionLiteral", "Identifier x", "TypeAnnotation", "TypeAnnotation",
+		"TypeAnnotation", "BlockStatement", "ReturnStatement",
+		"InfixExpression", "PrefixExpression", "Identifier x", "IntegerLiteral",
+		"ExpressionStatement", "IfExpression", "Boolean",
+		"BlockStatement", "ExpressionStatement", "IndexExpression", "CallExpression",
+		"Identifier f", "IntegerLiteral", "IntegerLiteral",
+		"BlockStatement", "ThrowStatement", "StringLiteral",
+		"LetStatement", "Identifier h", "HashLiteral",
+		"StringLiteral", "ArrayLiteral", "IntegerLiteral",
+		"IntegerLiteral", "MemberExpression", "ImportExpression", "Identifier v",
+		"ExpressionStatement", "TryExpression",
+		"BlockStatement", "ExpressionStatement", "Identifier h",
+		"Identifier e",
+		"BlockStatement", "ExpressionStatement", "Identifier e",
+		"BlockStatement", "ExpressionStatement", "IntegerLiteral",
+	}
+
+	if strings.Join(visited, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("visit order wrong.\nexpected=%v\ngot=%v", expected, visited)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	count := 0
+	Inspect(sampleProgram(), func(node Node) bool {
+		count++
+		_, isStatement := node.(Statement)
+		return !isStatement
+	})
+
+	// the program and its four statements
+	if count != 5 {
+		t.Errorf("wrong number of nodes visited. expected=5, got=%d", count)
+	}
+}
+
+type countingVisitor struct {
+	enter, leave *int
+}
+
+func (v countingVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		*v.leave++
+	} else {
+		*v.enter++
+	}
+	return v
+}
+
+func TestWalkVisitsNilAfterChildren(t *testing.T) {
+	var enter, leave int
+	Walk(countingVisitor{&enter, &leave}, sampleProgram())
+
+	if enter == 0 || enter != leave {
+		t.Errorf("nodes entered and left differ. enter=%d, leave=%d", enter, leave)
+	}
+}
+
+// TestAllNodeTypesSupported guards against node types added without support
+// in the JSON codec, Walk, Modify, Clone and Equal
+func TestAllNodeTypesSupported(t *testing.T) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every type with a TokenLiteral method is a node
+	var declared []string
+	for _, file := range pkgs["ast"].Files {
+		for _, decl := range file.Decls {
+			name, ok := tokenLiteralReceiver(decl)
+			if ok {
+				declared = append(declared, name)
+			}
+		}
+	}
+	if len(declared) == 0 {
+		t.Fatal("found no node types in the package source")
+	}
+
+	for _, name := range declared {
+		if _, ok := nodeTypes[name]; !ok {
+			t.Errorf("node type %s is missing from nodeTypes", name)
+		}
+	}
+
+	for name, typ := range nodeTypes {
+		node := reflect.New(typ).Interface().(Node)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s not supported: %v", name, r)
+				}
+			}()
+
+			Inspect(node, func(Node) bool { return true })
+			clone := Clone(node)
+			if !Equal(node, clone) {
+				t.Errorf("%s not Equal to its clone", name)
+			}
+			Modify(clone, func(n Node) Node { return n })
+			if _, err := EncodeJSON(node); err != nil {
+				t.Errorf("%s cannot be encoded: %s", name, err)
+			}
+		}()
+	}
+}
+
+// tokenLiteralReceiver the receiver type name of decl if it declares a
+// TokenLiteral method
+func tokenLiteralReceiver(decl goast.Decl) (string, bool) {
+	fn, ok := decl.(*goast.FuncDecl)
+	if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+		return "", false
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*goast.StarExpr); ok {
+		recv = star.X
+	}
+	ident, ok := recv.(*goast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
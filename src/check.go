@@ -0,0 +1,76 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"ast"
+	"check"
+	"flag"
+	"fmt"
+	"io"
+	"lexer"
+	"parser"
+)
+
+const checkUsage = `usage: monkey check [-globals names] [-types] [file ...]
+
+Infer the types of monkey source files, or standard input when there are
+none, without running them, and report type errors as file:line:column:
+message. Exit with status 1 when there are errors.
+
+flags:
+`
+
+// runCheck the check subcommand
+func runCheck(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, checkUsage)
+		flags.PrintDefaults()
+	}
+	globals := flags.String("globals", "", "comma separated `names` defined by the host program")
+	types := flags.Bool("types", false, "print the types of the top level let names")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg := &check.Config{Globals: splitList(*globals)}
+
+	return eachSource("check", flags.Args(), stdin, stderr, func(path string, src []byte) int {
+		return checkSource(path, string(src), cfg, *types, stdout, stderr)
+	})
+}
+
+func checkSource(path string, src string, cfg *check.Config, types bool, stdout io.Writer, stderr io.Writer) int {
+	p := parser.New(lexer.New(lexer.SkipShebang(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s: parse error: %s\n", path, msg)
+		}
+		return exitUsage
+	}
+
+	result := check.Program(program, cfg)
+
+	if types {
+		for _, stmt := range program.Statements {
+			if let, ok := stmt.(*ast.LetStatement); ok {
+				fmt.Fprintf(stdout, "%s: %s: %s\n", path, let.Name.Value, result.Types[let.Name])
+			}
+		}
+	}
+
+	for _, e := range result.Errors {
+		fmt.Fprintf(stdout, "%s:%s\n", path, e)
+	}
+	if len(result.Errors) != 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package check
+
+// builtinTypes the types of the builtin functions, by name. len takes any
+// value, the call being checked for a string or an array
+var builtinTypes = map[string]func() *scheme{
+	"len": func() *scheme {
+		return &scheme{t: &tfunc{params: []typ{tAny}, result: tInt}}
+	},
+	"first": func() *scheme {
+		return generic(func(a typ) typ { return &tfunc{params: []typ{arrayOf(a)}, result: a} })
+	},
+	"last": func() *scheme {
+		return generic(func(a typ) typ { return &tfunc{params: []typ{arrayOf(a)}, result: a} })
+	},
+	"rest": func() *scheme {
+		return generic(func(a typ) typ { return &tfunc{params: []typ{arrayOf(a)}, result: arrayOf(a)} })
+	},
+	"push": func() *scheme {
+		return generic(func(a typ) typ { return &tfunc{params: []typ{arrayOf(a), a}, result: arrayOf(a)} })
+	},
+	"puts": func() *scheme {
+		return &scheme{t: &tfunc{params: []typ{tAny}, result: tNull, variadic: true}}
+	},
+	"json_parse": func() *scheme {
+		return &scheme{t: &tfunc{params: []typ{tString}, result: tAny}}
+	},
+	"json_stringify": func() *scheme {
+		// the value, then an optional indent
+		return &scheme{t: &tfunc{params: []typ{tAny}, result: tString, variadic: true}}
+	},
+}
+
+// generic a scheme for the type f makes of a type variable, generic in it
+func generic(f func(a typ) typ) *scheme {
+	a := &tvar{}
+	return &scheme{vars: []*tvar{a}, t: f(a)}
+}
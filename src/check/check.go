@@ -0,0 +1,701 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package check infers the types of monkey programs and reports the type
+// errors it finds without running them.
+//
+// Types are inferred Hindley-Milner style. A name bound by let to a function
+// is generic, so that
+//
+//	let id = fn(x) { x };
+//	id(1) + len(id("a"))
+//
+// checks. Values whose type cannot be known, such as those of imports, caught
+// errors and parsed JSON, have the type any, which fits every other type.
+// Branches of an if and elements of arrays and hashes that cannot have the
+// same type give a union, which fits what one of its members fits. Type
+// annotations are checked against what is inferred.
+package check
+
+import (
+	"ast"
+	"evaluator"
+	"fmt"
+	"lexer"
+	"parser"
+	"sort"
+	"strings"
+	"token"
+)
+
+// Error a type error found in a program
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Config what the checker knows about the host
+type Config struct {
+	// Globals names defined by the host, through monkey.Interpreter.Set or
+	// Register for instance, which have the type any
+	Globals []string
+}
+
+// ParseError the source could not be parsed, so it was not checked
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Result what checking a program found
+type Result struct {
+	// Errors sorted by position
+	Errors []Error
+	// Types the types of the identifiers in the program: let names, function
+	// and catch parameters and references. Those of let bound functions are
+	// generic in their type variables, named a, b, ...
+	Types map[*ast.Identifier]string
+}
+
+// Source check the monkey program src. A nil config checks it as a script
+// run by the monkey command
+func Source(src string, cfg *Config) (*Result, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	return Program(program, cfg), nil
+}
+
+// Program check program. A nil config checks it as a script run by the
+// monkey command
+func Program(program *ast.Program, cfg *Config) *Result {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	c := &checker{idents: map[*ast.Identifier]*scheme{}}
+
+	root := newScope(nil, 0)
+	for _, name := range evaluator.BuiltinNames() {
+		sig, ok := builtinTypes[name]
+		if !ok {
+			root.names[name] = &scheme{t: tAny}
+			continue
+		}
+		root.names[name] = sig()
+	}
+	for _, name := range cfg.Globals {
+		root.names[name] = &scheme{t: tAny}
+	}
+	c.root = root
+	c.scope = root
+
+	c.openScope(program)
+	c.statements(program.Statements)
+	c.closeScope()
+
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i], c.errors[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	types := make(map[*ast.Identifier]string, len(c.idents))
+	for ident, s := range c.idents {
+		types[ident] = typeString(s.t)
+	}
+	return &Result{Errors: c.errors, Types: types}
+}
+
+type scope struct {
+	parent *scope
+	// level the let nesting depth the scope was opened at
+	level int
+	names map[string]*scheme
+	// lets names bound by let statements directly in the scope, which may be
+	// used by functions before the let statement is reached
+	lets map[string]bool
+	// pending the types of the lets used before their let statement
+	pending map[string]*tvar
+}
+
+func newScope(parent *scope, level int) *scope {
+	return &scope{
+		parent:  parent,
+		level:   level,
+		names:   map[string]*scheme{},
+		lets:    map[string]bool{},
+		pending: map[string]*tvar{},
+	}
+}
+
+// function the function literal being checked
+type function struct {
+	// result the join of the types of its return statements, nil if none
+	result typ
+}
+
+type checker struct {
+	// level let nesting depth
+	level int
+	trail []undo
+
+	root      *scope
+	scope     *scope
+	functions []*function
+
+	idents map[*ast.Identifier]*scheme
+	errors []Error
+}
+
+func (c *checker) errorf(tok token.Token, format string, args ...interface{}) {
+	c.errors = append(c.errors, Error{
+		Message: fmt.Sprintf(format, args...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+// mismatch report that got cannot be used as want where context says
+func (c *checker) mismatch(tok token.Token, err *unifyError, want, got typ, context string) {
+	names := typeStrings(want, got)
+	switch {
+	case err.addable:
+		c.errorf(tok, "cannot use %s as int or string in %s", typeString(err.t), context)
+	case err.occurs:
+		c.errorf(tok, "cannot use %s as %s in %s: infinite type", names[1], names[0], context)
+	default:
+		c.errorf(tok, "cannot use %s as %s in %s", names[1], names[0], context)
+	}
+}
+
+func (c *checker) fresh() *tvar {
+	return &tvar{level: c.level}
+}
+
+// openScope start a scope and note the let bindings made directly in body,
+// wherever they are. Let bindings in if blocks belong to the enclosing
+// scope, as when evaluating
+func (c *checker) openScope(body ast.Node) {
+	c.scope = newScope(c.scope, c.level)
+
+	var declare func(node ast.Node) bool
+	declare = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			// the catch block is a scope of its own
+			ast.Inspect(node.Block, declare)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, declare)
+			}
+			return false
+		case *ast.LetStatement:
+			c.scope.lets[node.Name.Value] = true
+		}
+		return true
+	}
+	ast.Inspect(body, declare)
+}
+
+func (c *checker) closeScope() {
+	c.scope = c.scope.parent
+}
+
+// declare bind ident to s in the current scope
+func (c *checker) declare(ident *ast.Identifier, s *scheme) {
+	c.scope.names[ident.Value] = s
+	c.idents[ident] = s
+}
+
+// lookup the type of a reference to ident
+func (c *checker) lookup(ident *ast.Identifier) typ {
+	for s := c.scope; s != nil; s = s.parent {
+		if sc, ok := s.names[ident.Value]; ok {
+			return c.instantiate(sc)
+		}
+		if s.lets[ident.Value] {
+			// used before its let statement, by a function called later
+			v := &tvar{level: s.level}
+			s.pending[ident.Value] = v
+			s.names[ident.Value] = &scheme{t: v}
+			return v
+		}
+	}
+
+	c.errorf(ident.Token, "identifier not found: %s", ident.Value)
+	return tAny
+}
+
+// isBuiltin report whether name refers to the builtin function of that name
+func (c *checker) isBuiltin(name string) bool {
+	for s := c.scope; s != c.root; s = s.parent {
+		if _, ok := s.names[name]; ok || s.lets[name] {
+			return false
+		}
+	}
+	_, ok := builtinTypes[name]
+	return ok
+}
+
+// statements check stmts, returning the type of the value of the last one
+func (c *checker) statements(stmts []ast.Statement) typ {
+	var t typ = tNull
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			c.let(stmt)
+			t = tNull
+		case *ast.ReturnStatement:
+			result := c.expr(stmt.ReturnValue)
+			if len(c.functions) > 0 {
+				fn := c.functions[len(c.functions)-1]
+				if fn.result == nil {
+					fn.result = result
+				} else {
+					fn.result = c.join(fn.result, result)
+				}
+			}
+			// the block has no value of its own, which fits any other
+			t = c.fresh()
+		case *ast.ThrowStatement:
+			c.expr(stmt.Value)
+			t = c.fresh()
+		case *ast.ExpressionStatement:
+			t = c.expr(stmt.Expression)
+		}
+	}
+
+	return t
+}
+
+func (c *checker) block(block *ast.BlockStatement) typ {
+	if block == nil {
+		return tNull
+	}
+	return c.statements(block.Statements)
+}
+
+func (c *checker) let(stmt *ast.LetStatement) {
+	name := stmt.Name
+
+	c.level++
+	var self *tvar
+	if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		// the function may call itself by the name it is bound to
+		self = c.fresh()
+		c.scope.names[name.Value] = &scheme{t: self}
+	}
+
+	t := c.expr(stmt.Value)
+	if self != nil {
+		if err := c.unify(self, t); err != nil {
+			c.mismatch(name.Token, err, self, t, "let "+name.Value)
+		}
+	}
+	if name.Type != nil {
+		want := c.annotation(name.Type)
+		if err := c.unify(want, t); err != nil {
+			c.mismatch(name.Token, err, want, t, "let "+name.Value)
+			t = want
+		}
+	}
+	c.level--
+
+	if v := c.scope.pending[name.Value]; v != nil {
+		delete(c.scope.pending, name.Value)
+		if err := c.unify(v, t); err != nil {
+			c.mismatch(name.Token, err, v, t, "let "+name.Value)
+		}
+	}
+
+	// only values that run nothing when bound are generic, the elements of
+	// let xs = [] must have one type
+	switch stmt.Value.(type) {
+	case *ast.FunctionLiteral, *ast.Identifier:
+		c.declare(name, c.generalize(t))
+	default:
+		c.declare(name, &scheme{t: t})
+	}
+}
+
+func (c *checker) expr(node ast.Expression) typ {
+	switch node := node.(type) {
+	case nil:
+		return tNull
+	case *ast.IntegerLiteral:
+		return tInt
+	case *ast.StringLiteral:
+		return tString
+	case *ast.Boolean:
+		return tBool
+	case *ast.Identifier:
+		t := c.lookup(node)
+		c.idents[node] = &scheme{t: t}
+		return t
+	case *ast.PrefixExpression:
+		right := c.expr(node.Right)
+		if node.Operator == "!" {
+			return tBool
+		}
+		if c.unify(tInt, right) != nil {
+			c.errorf(node.Token, "unknown operator: %s%s", node.Operator, typeString(right))
+			return tAny
+		}
+		return tInt
+	case *ast.InfixExpression:
+		return c.infix(node)
+	case *ast.IfExpression:
+		c.expr(node.Condition)
+		consequence := c.block(node.Consequence)
+		if node.Alternative != nil {
+			return c.join(consequence, c.block(node.Alternative))
+		}
+		return c.orNull(consequence)
+	case *ast.FunctionLiteral:
+		return c.function(node)
+	case *ast.CallExpression:
+		return c.call(node)
+	case *ast.ArrayLiteral:
+		var elem typ = c.fresh()
+		for _, el := range node.Elements {
+			elem = c.join(elem, c.expr(el))
+		}
+		return arrayOf(elem)
+	case *ast.HashLiteral:
+		return c.hash(node)
+	case *ast.IndexExpression:
+		return c.index(node)
+	case *ast.TryExpression:
+		t := c.block(node.Block)
+		if node.Catch != nil {
+			c.openScope(node.Catch)
+			// anything may be thrown
+			c.declare(node.Param, &scheme{t: tAny})
+			t = c.join(t, c.block(node.Catch))
+			c.closeScope()
+		}
+		c.block(node.Finally)
+		return t
+	case *ast.ImportExpression:
+		return tModule
+	case *ast.MemberExpression:
+		return c.member(node)
+	default:
+		return tAny
+	}
+}
+
+// orNull the type of an if without else whose consequence has type t
+func (c *checker) orNull(t typ) typ {
+	switch prune(t).(type) {
+	case *tvar, tany:
+		return t
+	}
+	if c.fits(t, tNull) {
+		return t
+	}
+	return c.join(t, tNull)
+}
+
+func (c *checker) infix(node *ast.InfixExpression) typ {
+	left := c.expr(node.Left)
+	right := c.expr(node.Right)
+
+	switch node.Operator {
+	case "==", "!=":
+		// values of different types are unequal
+		return tBool
+	case "+":
+		operand := c.fresh()
+		operand.addable = true
+		if c.unify(operand, left) != nil || c.unify(operand, right) != nil {
+			c.operandError(node, left, right)
+			return tAny
+		}
+		return operand
+	default:
+		if c.unify(tInt, left) != nil || c.unify(tInt, right) != nil {
+			c.operandError(node, left, right)
+			return tAny
+		}
+		if node.Operator == "<" || node.Operator == ">" {
+			return tBool
+		}
+		return tInt
+	}
+}
+
+// operandError report operands of node it does not apply to, as evaluating
+// it would
+func (c *checker) operandError(node *ast.InfixExpression, left, right typ) {
+	names := typeStrings(left, right)
+	if names[0] != names[1] {
+		c.errorf(node.Token, "type mismatch: %s %s %s", names[0], node.Operator, names[1])
+	} else {
+		c.errorf(node.Token, "unknown operator: %s %s %s", names[0], node.Operator, names[1])
+	}
+}
+
+func (c *checker) function(fl *ast.FunctionLiteral) typ {
+	c.openScope(fl.Body)
+	defer c.closeScope()
+
+	params := make([]typ, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		if param.Type != nil {
+			params[i] = c.annotation(param.Type)
+		} else {
+			params[i] = c.fresh()
+		}
+		c.declare(param, &scheme{t: params[i]})
+	}
+
+	fn := &function{}
+	c.functions = append(c.functions, fn)
+	result := c.block(fl.Body)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	if fn.result != nil {
+		result = c.join(fn.result, result)
+	}
+
+	if fl.ReturnType != nil {
+		want := c.annotation(fl.ReturnType)
+		if err := c.unify(want, result); err != nil {
+			c.mismatch(fl.ReturnType.Token, err, want, result, "result of "+describeFunction(fl.Name))
+			result = want
+		}
+	}
+
+	return &tfunc{params: params, result: result}
+}
+
+func describeFunction(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+func (c *checker) call(ce *ast.CallExpression) typ {
+	callee := c.expr(ce.Function)
+	args := make([]typ, len(ce.Arguments))
+	for i, arg := range ce.Arguments {
+		args[i] = c.expr(arg)
+	}
+
+	switch fn := prune(callee).(type) {
+	case *tfunc:
+		if !fn.variadic && len(args) != len(fn.params) {
+			c.errorf(ce.Token, "wrong number of arguments. got=%d, want=%d", len(args), len(fn.params))
+			return fn.result
+		}
+		for i, arg := range args {
+			param := fn.params[0]
+			if !fn.variadic {
+				param = fn.params[i]
+			}
+			if err := c.unify(param, arg); err != nil {
+				context := fmt.Sprintf("argument %d to %s", i+1, ce.Function.String())
+				c.mismatch(exprToken(ce.Arguments[i]), err, param, arg, context)
+				return tAny
+			}
+		}
+		if ident, ok := ce.Function.(*ast.Identifier); ok && ident.Value == "len" && len(args) == 1 && c.isBuiltin("len") {
+			c.checkLen(ce, args[0])
+		}
+		return fn.result
+	case *tvar:
+		result := c.fresh()
+		want := &tfunc{params: args, result: result}
+		if err := c.unify(fn, want); err != nil {
+			c.mismatch(ce.Token, err, want, fn, "call of "+ce.Function.String())
+			return tAny
+		}
+		return result
+	case tany, *tunion:
+		return tAny
+	default:
+		c.errorf(ce.Token, "not a function: %s", typeString(fn))
+		return tAny
+	}
+}
+
+// checkLen check the argument of the builtin len, a string or an array
+func (c *checker) checkLen(ce *ast.CallExpression, arg typ) {
+	switch t := prune(arg).(type) {
+	case *tcon:
+		if t.name == "string" || t.name == "array" {
+			return
+		}
+	case *tfunc:
+	default:
+		return
+	}
+	c.errorf(exprToken(ce.Arguments[0]), "argument to `len` not supported, got %s", typeString(arg))
+}
+
+func (c *checker) hash(hl *ast.HashLiteral) typ {
+	var key, value typ = c.fresh(), c.fresh()
+
+	for _, keyNode := range hl.OrderedKeys() {
+		k := c.expr(keyNode)
+		if !hashable(k) {
+			c.errorf(exprToken(keyNode), "unusable as hash key: %s", typeString(k))
+		}
+		key = c.join(key, k)
+		value = c.join(value, c.expr(hl.Pairs[keyNode]))
+	}
+
+	return hashOf(key, value)
+}
+
+// hashable report whether a value of type t may be a hash key
+func hashable(t typ) bool {
+	switch t := prune(t).(type) {
+	case *tcon:
+		return t.name == "int" || t.name == "string" || t.name == "bool"
+	case *tfunc:
+		return false
+	}
+	return true
+}
+
+func (c *checker) index(ie *ast.IndexExpression) typ {
+	left := c.expr(ie.Left)
+	index := c.expr(ie.Index)
+
+	switch t := prune(left).(type) {
+	case *tcon:
+		switch t.name {
+		case "array":
+			if err := c.unify(tInt, index); err != nil {
+				c.mismatch(exprToken(ie.Index), err, tInt, index, "array index")
+			}
+			return t.args[0]
+		case "hash":
+			if err := c.unify(t.args[0], index); err != nil {
+				c.mismatch(exprToken(ie.Index), err, t.args[0], index, "hash index")
+			}
+			return t.args[1]
+		case "module":
+			return tAny
+		}
+	case *tfunc:
+	default:
+		// an array or a hash, which one is not known
+		return tAny
+	}
+
+	c.errorf(ie.Token, "index operator not supported: %s", typeString(left))
+	return tAny
+}
+
+func (c *checker) member(me *ast.MemberExpression) typ {
+	object := c.expr(me.Object)
+
+	switch t := prune(object).(type) {
+	case *tcon:
+		switch t.name {
+		case "hash":
+			if err := c.unify(t.args[0], tString); err != nil {
+				c.mismatch(me.Property.Token, err, t.args[0], tString, "member access")
+			}
+			return t.args[1]
+		case "module":
+			return tAny
+		}
+	case *tfunc:
+	default:
+		return tAny
+	}
+
+	c.errorf(me.Property.Token, "member access not supported: %s", typeString(object))
+	return tAny
+}
+
+// annotation the type an annotation stands for. Element types left out are
+// inferred
+func (c *checker) annotation(ta *ast.TypeAnnotation) typ {
+	param := func(i int) typ {
+		if i < len(ta.Params) {
+			return c.annotation(ta.Params[i])
+		}
+		return c.fresh()
+	}
+
+	switch ta.Name {
+	case "int":
+		return tInt
+	case "float":
+		return tFloat
+	case "string":
+		return tString
+	case "bool":
+		return tBool
+	case "null":
+		return tNull
+	case "array":
+		return arrayOf(param(0))
+	case "hash":
+		return hashOf(param(0), param(1))
+	default:
+		// any, and fn which does not say what the function takes
+		return tAny
+	}
+}
+
+// exprToken the token an expression starts at, or its operator
+func exprToken(expr ast.Expression) token.Token {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return expr.Token
+	case *ast.IntegerLiteral:
+		return expr.Token
+	case *ast.StringLiteral:
+		return expr.Token
+	case *ast.Boolean:
+		return expr.Token
+	case *ast.PrefixExpression:
+		return expr.Token
+	case *ast.InfixExpression:
+		return expr.Token
+	case *ast.IfExpression:
+		return expr.Token
+	case *ast.FunctionLiteral:
+		return expr.Token
+	case *ast.CallExpression:
+		return expr.Token
+	case *ast.ArrayLiteral:
+		return expr.Token
+	case *ast.IndexExpression:
+		return expr.Token
+	case *ast.HashLiteral:
+		return expr.Token
+	case *ast.TryExpression:
+		return expr.Token
+	case *ast.ImportExpression:
+		return expr.Token
+	case *ast.MemberExpression:
+		return expr.Token
+	default:
+		panic(fmt.Sprintf("check: unexpected expression %T", expr))
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package check
+
+import (
+	"ast"
+	"evaluator"
+	"lexer"
+	"parser"
+	"strings"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + 2", nil},
+		{`1 + "a"`, []string{"1:3: type mismatch: int + string"}},
+		{"true + false", []string{"1:6: unknown operator: bool + bool"}},
+		{`"a" - "b"`, []string{"1:5: unknown operator: string - string"}},
+		{`-"a"`, []string{"1:1: unknown operator: -string"}},
+		{`1 == "a"; !5`, nil},
+		{"5(1)", []string{"1:2: not a function: int"}},
+		{"let f = fn(a) { a }; f(1, 2)", []string{"1:23: wrong number of arguments. got=2, want=1"}},
+		{"let f = fn(a) { a + 1 }; f(\"a\")", []string{"1:28: cannot use string as int in argument 1 to f"}},
+		{"let add = fn(a, b) { a + b }; add(1, 2); add(\"a\", \"b\"); add(true, 1)", []string{
+			"1:61: cannot use bool as int or string in argument 1 to add",
+		}},
+		{"let f = fn(g) { g(1) + 1 }; f(fn(s) { s + \"!\" })", []string{
+			"1:31: cannot use fn(string) -> string as fn(int) -> int in argument 1 to f",
+		}},
+		{"let f = fn(g) { g(g) };", []string{"1:18: cannot use a as fn(a) -> b in call of g: infinite type"}},
+		{"len(5)", []string{"1:5: argument to `len` not supported, got int"}},
+		{"let len = fn(x) { x }; len(5)", nil},
+		{"push([1], \"a\")", []string{"1:11: cannot use string as int in argument 2 to push"}},
+		{"first([1]) + first([\"a\"])", []string{"1:12: type mismatch: int + string"}},
+		{"json_parse(\"1\") + 1; puts(1, \"a\", [])", nil},
+		{"json_stringify([1]) + json_stringify({}, 2)", nil},
+		{"[1, 2][\"a\"]", []string{"1:8: cannot use string as int in array index"}},
+		{"{\"a\": 1}[1]", []string{"1:10: cannot use int as string in hash index"}},
+		{"5[0]", []string{"1:2: index operator not supported: int"}},
+		{"{[1]: 2}", []string{"1:2: unusable as hash key: array<int>"}},
+		{"let h = {\"a\": 1}; h.a + 1; h.b + \"x\"", []string{"1:32: type mismatch: int + string"}},
+		{"5.size", []string{"1:3: member access not supported: int"}},
+		{"let m = import \"lib\"; m.f(1) + m.g", nil},
+		{"puts(y)", []string{"1:6: identifier not found: y"}},
+		// a union fits what one of its members fits
+		{"let xs = [1, \"a\"]; xs[0] + 1; xs[1] + \"b\"; xs[0] + true", []string{
+			"1:50: type mismatch: int | string + bool",
+		}},
+		{"let x = if (true) { 1 }; x + 1", nil},
+		{"let x = if (true) { 1 } else { \"a\" }; len(x)", []string{}},
+		{"let f = fn(n) { if (n > 0) { return \"pos\" } \"neg\" }; f(1) + 1", []string{"1:59: type mismatch: string + int"}},
+		{"let f = fn() { throw \"no\" }; f() + 1", nil},
+		{"try { 1 } catch (e) { e + 1 }", nil},
+		// let polymorphism
+		{"let id = fn(x) { x }; id(1) + len(id(\"a\"))", nil},
+		{"let xs = []; push(xs, 1); push(xs, \"a\")", []string{"1:36: cannot use string as int in argument 2 to push"}},
+		// mutual recursion through names bound later
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(\"a\")", []string{
+			"1:133: cannot use string as int in argument 1 to even",
+		}},
+		// annotations
+		{"let x: string = 5;", []string{"1:5: cannot use int as string in let x"}},
+		{"let f = fn(a: int) -> string { a };", []string{"1:23: cannot use int as string in result of f"}},
+		{"let f = fn(a: array<int>) { a }; f([\"a\"])", []string{"1:36: cannot use array<string> as array<int> in argument 1 to f"}},
+		{"let f = fn(a: any) { a + 1 }; f(\"a\")", nil},
+	}
+
+	for _, tt := range tests {
+		result, err := Source(tt.input, nil)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+
+		var got []string
+		for _, e := range result.Errors {
+			got = append(got, e.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;", "int"},
+		{"let x = [1, 2];", "array<int>"},
+		{"let x = [];", "array<a>"},
+		{"let x = [1, \"a\"];", "array<int | string>"},
+		{"let x = {\"a\": true};", "hash<string, bool>"},
+		{"let x = fn(a) { a };", "fn(a) -> a"},
+		{"let x = fn(a, b) { a + b };", "fn(a, a) -> a"},
+		{"let x = fn(f, g) { fn(v) { f(g(v)) } };", "fn(fn(a) -> b, fn(c) -> a) -> fn(c) -> b"},
+		{"let x = fn(xs) { if (len(xs) == 0) { 0 } else { first(xs) } };", "fn(array<int>) -> int"},
+		{"let x = fn(n) { if (n < 1) { 1 } else { n * x(n - 1) } };", "fn(int) -> int"},
+		{"let x = if (true) { \"a\" };", "string | null"},
+		{"let x = import \"lib\";", "module"},
+		{"let x = json_parse(\"1\");", "any"},
+		{
+			`let map = fn(arr, f) {
+  let iter = fn(arr, acc) {
+    if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) }
+  };
+  iter(arr, [])
+};
+let x = map([1, 2], fn(n) { n > 1 });`,
+			"array<bool>",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		result := Program(program, nil)
+		if len(result.Errors) != 0 {
+			t.Errorf("Program(%q) returned errors: %v", tt.input, result.Errors)
+			continue
+		}
+
+		last := program.Statements[len(program.Statements)-1].(*ast.LetStatement)
+		if got := result.Types[last.Name]; got != tt.expected {
+			t.Errorf("type of x in %q wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	result, err := Source("host(1) + x", &Config{Globals: []string{"host"}})
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Message != "identifier not found: x" {
+		t.Errorf("wrong errors. got=%v", result.Errors)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Source("let = 1;", nil)
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("err is not *ParseError. got=%T (%v)", err, err)
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	for _, name := range evaluator.BuiltinNames() {
+		if _, ok := builtinTypes[name]; !ok {
+			t.Errorf("builtin %s has no type", name)
+		}
+	}
+}
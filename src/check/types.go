@@ -0,0 +1,481 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package check
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typ a monkey type: a type variable, a named type such as int or
+// array<int>, a function type, a union or any
+type typ interface {
+	isType()
+}
+
+// tvar type variable. A bound variable stands for ref. level is the let
+// nesting depth it was made at, variables made deeper than a let are
+// generalized by it
+type tvar struct {
+	ref   typ
+	level int
+	// addable restricts the variable to int and string, the operands of +
+	addable bool
+}
+
+// tcon named type with element types: int, bool, string, null, float, module,
+// array<T> and hash<K, V>
+type tcon struct {
+	name string
+	args []typ
+}
+
+// tfunc function type. A variadic function takes any number of arguments of
+// its only parameter type
+type tfunc struct {
+	params   []typ
+	result   typ
+	variadic bool
+}
+
+// tunion one of several types, given to values that may have either, such as
+// the elements of [1, "a"]
+type tunion struct {
+	types []typ
+}
+
+// tany dynamic type, what the checker knows nothing about, compatible with
+// every other type
+type tany struct{}
+
+func (*tvar) isType()   {}
+func (*tcon) isType()   {}
+func (*tfunc) isType()  {}
+func (*tunion) isType() {}
+func (tany) isType()    {}
+
+var (
+	tInt    = &tcon{name: "int"}
+	tBool   = &tcon{name: "bool"}
+	tString = &tcon{name: "string"}
+	tNull   = &tcon{name: "null"}
+	tFloat  = &tcon{name: "float"}
+	tModule = &tcon{name: "module"}
+	tAny    = tany{}
+)
+
+func arrayOf(elem typ) *tcon      { return &tcon{name: "array", args: []typ{elem}} }
+func hashOf(key, value typ) *tcon { return &tcon{name: "hash", args: []typ{key, value}} }
+
+// prune follow bound variables to the type they stand for
+func prune(t typ) typ {
+	for {
+		v, ok := t.(*tvar)
+		if !ok || v.ref == nil {
+			return t
+		}
+		t = v.ref
+	}
+}
+
+// scheme type of a let bound name, generic in vars
+type scheme struct {
+	vars []*tvar
+	t    typ
+}
+
+// unifyError why two types could not be unified
+type unifyError struct {
+	// occurs is set when a variable would contain itself, addable when a
+	// variable restricted to int and string met another type
+	occurs, addable bool
+	t               typ
+}
+
+// undo the state of a variable before the checker changed it
+type undo struct {
+	v       *tvar
+	ref     typ
+	level   int
+	addable bool
+}
+
+// save record the state of v, to restore it should the unification in
+// progress fail
+func (c *checker) save(v *tvar) {
+	c.trail = append(c.trail, undo{v, v.ref, v.level, v.addable})
+}
+
+// rollback restore the variables changed since the trail had length mark
+func (c *checker) rollback(mark int) {
+	for i := len(c.trail) - 1; i >= mark; i-- {
+		u := c.trail[i]
+		u.v.ref, u.v.level, u.v.addable = u.ref, u.level, u.addable
+	}
+	c.trail = c.trail[:mark]
+}
+
+// unify make a and b the same type, binding variables as needed. Nothing is
+// bound if they cannot be made the same
+func (c *checker) unify(a, b typ) *unifyError {
+	mark := len(c.trail)
+	err := c.unifyTypes(a, b)
+	if err != nil {
+		c.rollback(mark)
+	} else {
+		// unify is not nested in another unification that could fail, so
+		// what it did is kept for good
+		c.trail = c.trail[:mark]
+	}
+	return err
+}
+
+// fits report whether a and b could be unified, binding nothing
+func (c *checker) fits(a, b typ) bool {
+	mark := len(c.trail)
+	err := c.unifyTypes(a, b)
+	c.rollback(mark)
+	return err == nil
+}
+
+func (c *checker) unifyTypes(a, b typ) *unifyError {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return nil
+	}
+
+	// any binds nothing, a variable unified with it is still to be inferred
+	if _, ok := a.(tany); ok {
+		return nil
+	}
+	if _, ok := b.(tany); ok {
+		return nil
+	}
+
+	if v, ok := a.(*tvar); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*tvar); ok {
+		return c.bind(v, a)
+	}
+
+	// a union is compatible with what one of its members is compatible with,
+	// which binds nothing since it is not known which member a value has
+	if u, ok := a.(*tunion); ok {
+		return c.unifyUnion(u, b)
+	}
+	if u, ok := b.(*tunion); ok {
+		return c.unifyUnion(u, a)
+	}
+
+	switch a := a.(type) {
+	case *tcon:
+		b, ok := b.(*tcon)
+		if !ok || a.name != b.name || len(a.args) != len(b.args) {
+			return &unifyError{}
+		}
+		for i := range a.args {
+			if err := c.unifyTypes(a.args[i], b.args[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *tfunc:
+		b, ok := b.(*tfunc)
+		if !ok {
+			return &unifyError{}
+		}
+		if a.variadic || b.variadic {
+			return c.unifyTypes(a.result, b.result)
+		}
+		if len(a.params) != len(b.params) {
+			return &unifyError{}
+		}
+		for i := range a.params {
+			if err := c.unifyTypes(a.params[i], b.params[i]); err != nil {
+				return err
+			}
+		}
+		return c.unifyTypes(a.result, b.result)
+	}
+	return &unifyError{}
+}
+
+func (c *checker) unifyUnion(u *tunion, t typ) *unifyError {
+	if _, ok := t.(*tunion); ok {
+		return nil
+	}
+	for _, member := range u.types {
+		if c.fits(member, t) {
+			return nil
+		}
+	}
+	return &unifyError{}
+}
+
+// bind make v stand for t
+func (c *checker) bind(v *tvar, t typ) *unifyError {
+	if c.occurs(v, t) {
+		return &unifyError{occurs: true, t: t}
+	}
+
+	if v.addable {
+		switch t := t.(type) {
+		case *tvar:
+			if !t.addable {
+				c.save(t)
+				t.addable = true
+			}
+		case *tcon:
+			if t.name != "int" && t.name != "string" {
+				return &unifyError{addable: true, t: t}
+			}
+		case *tfunc:
+			return &unifyError{addable: true, t: t}
+		}
+	}
+
+	c.lower(t, v.level)
+	c.save(v)
+	v.ref = t
+	return nil
+}
+
+// occurs report whether v appears in t
+func (c *checker) occurs(v *tvar, t typ) bool {
+	switch t := prune(t).(type) {
+	case *tvar:
+		return t == v
+	case *tcon:
+		for _, arg := range t.args {
+			if c.occurs(v, arg) {
+				return true
+			}
+		}
+	case *tfunc:
+		for _, param := range t.params {
+			if c.occurs(v, param) {
+				return true
+			}
+		}
+		return c.occurs(v, t.result)
+	case *tunion:
+		for _, member := range t.types {
+			if c.occurs(v, member) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lower the level of the variables in t to at most level, so that a let does
+// not generalize variables that escape into an enclosing one
+func (c *checker) lower(t typ, level int) {
+	switch t := prune(t).(type) {
+	case *tvar:
+		if t.level > level {
+			c.save(t)
+			t.level = level
+		}
+	case *tcon:
+		for _, arg := range t.args {
+			c.lower(arg, level)
+		}
+	case *tfunc:
+		for _, param := range t.params {
+			c.lower(param, level)
+		}
+		c.lower(t.result, level)
+	case *tunion:
+		for _, member := range t.types {
+			c.lower(member, level)
+		}
+	}
+}
+
+// generalize the type t of a let value over the variables made inside the
+// let
+func (c *checker) generalize(t typ) *scheme {
+	s := &scheme{t: t}
+	seen := map[*tvar]bool{}
+
+	var collect func(t typ)
+	collect = func(t typ) {
+		switch t := prune(t).(type) {
+		case *tvar:
+			if t.level > c.level && !seen[t] {
+				seen[t] = true
+				s.vars = append(s.vars, t)
+			}
+		case *tcon:
+			for _, arg := range t.args {
+				collect(arg)
+			}
+		case *tfunc:
+			for _, param := range t.params {
+				collect(param)
+			}
+			collect(t.result)
+		case *tunion:
+			for _, member := range t.types {
+				collect(member)
+			}
+		}
+	}
+	collect(t)
+
+	return s
+}
+
+// instantiate s with fresh variables for its generic ones
+func (c *checker) instantiate(s *scheme) typ {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+
+	fresh := make(map[*tvar]*tvar, len(s.vars))
+	for _, v := range s.vars {
+		nv := c.fresh()
+		nv.addable = v.addable
+		fresh[v] = nv
+	}
+
+	var copyType func(t typ) typ
+	copyType = func(t typ) typ {
+		switch t := prune(t).(type) {
+		case *tvar:
+			if nv, ok := fresh[t]; ok {
+				return nv
+			}
+			return t
+		case *tcon:
+			if len(t.args) == 0 {
+				return t
+			}
+			args := make([]typ, len(t.args))
+			for i, arg := range t.args {
+				args[i] = copyType(arg)
+			}
+			return &tcon{name: t.name, args: args}
+		case *tfunc:
+			params := make([]typ, len(t.params))
+			for i, param := range t.params {
+				params[i] = copyType(param)
+			}
+			return &tfunc{params: params, result: copyType(t.result), variadic: t.variadic}
+		case *tunion:
+			types := make([]typ, len(t.types))
+			for i, member := range t.types {
+				types[i] = copyType(member)
+			}
+			return &tunion{types: types}
+		default:
+			return t
+		}
+	}
+	return copyType(s.t)
+}
+
+// join the types of two values an expression may evaluate to, such as the
+// branches of an if. Types that cannot be unified make a union
+func (c *checker) join(a, b typ) typ {
+	if c.unify(a, b) == nil {
+		return a
+	}
+
+	var types []typ
+	add := func(t typ) {
+		if u, ok := t.(*tunion); ok {
+			for _, member := range u.types {
+				types = appendType(types, member)
+			}
+			return
+		}
+		types = appendType(types, t)
+	}
+	add(prune(a))
+	add(prune(b))
+	return &tunion{types: types}
+}
+
+// appendType add t to types unless it is there already
+func appendType(types []typ, t typ) []typ {
+	s := typeString(t)
+	for _, other := range types {
+		if typeString(other) == s {
+			return types
+		}
+	}
+	return append(types, t)
+}
+
+// typeString print t, naming its variables a, b, ...
+func typeString(t typ) string {
+	return typeStrings(t)[0]
+}
+
+// typeStrings print types with the same names for the same variables
+func typeStrings(types ...typ) []string {
+	p := &typePrinter{names: map[*tvar]string{}}
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = p.print(t)
+	}
+	return out
+}
+
+type typePrinter struct {
+	names map[*tvar]string
+}
+
+func (p *typePrinter) print(t typ) string {
+	switch t := prune(t).(type) {
+	case *tvar:
+		name, ok := p.names[t]
+		if !ok {
+			name = varName(len(p.names))
+			p.names[t] = name
+		}
+		return name
+	case *tcon:
+		if len(t.args) == 0 {
+			return t.name
+		}
+		return t.name + "<" + p.list(t.args) + ">"
+	case *tfunc:
+		params := p.list(t.params)
+		if t.variadic {
+			params += "..."
+		}
+		return "fn(" + params + ") -> " + p.print(t.result)
+	case *tunion:
+		members := make([]string, len(t.types))
+		for i, member := range t.types {
+			members[i] = p.print(member)
+		}
+		return strings.Join(members, " | ")
+	default:
+		return "any"
+	}
+}
+
+func (p *typePrinter) list(types []typ) string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = p.print(t)
+	}
+	return strings.Join(out, ", ")
+}
+
+// varName a, b, ..., z, a1, b1, ...
+func varName(i int) string {
+	name := string(rune('a' + i%26))
+	if i >= 26 {
+		name += fmt.Sprint(i / 26)
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	tests := []struct {
+		flags          []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+	}{
+		{nil, "let x = 1; x + 2", 0, ""},
+		{nil, "#!monkey\n1 + \"a\"", 1, "<stdin>:2:3: type mismatch: int + string\n"},
+		{nil, "puts(y)", 1, "<stdin>:1:6: identifier not found: y\n"},
+		{[]string{"-globals", "y"}, "puts(y)", 0, ""},
+		{[]string{"-types"}, "let id = fn(x) { x }; let n = id(1);", 0, "<stdin>: id: fn(a) -> a\n<stdin>: n: int\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"check"}, tt.flags...)
+		code := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("check %q of %q exit code wrong. expected=%d, got=%d", tt.flags, tt.stdin, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("check %q of %q stdout wrong. expected=%q, got=%q", tt.flags, tt.stdin, tt.expectedStdout, stdout.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("check %q of %q wrote to stderr: %q", tt.flags, tt.stdin, stderr.String())
+		}
+	}
+}
+
+// TestRunCheckFiles checks that every file is checked, and that the exit code
+// is the worst of them
+func TestRunCheckFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clean := filepath.Join(dir, "clean.mk")
+	wrong := filepath.Join(dir, "wrong.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	missing := filepath.Join(dir, "missing.mk")
+	for path, src := range map[string]string{clean: "1 + 2", wrong: "1 + true", broken: "let = 1"} {
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		files          []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{clean}, 0, "", ""},
+		{[]string{wrong, clean}, 1, wrong + ":1:3: type mismatch: int + bool\n", ""},
+		{[]string{broken, wrong}, exitUsage, wrong + ":1:3: type mismatch: int + bool\n",
+			broken + ": parse error: expected next token to be IDENT, got = instead\n" +
+				broken + ": parse error: no prefix parse function for = found\n"},
+		{[]string{missing, clean}, exitUsage, "", "monkey check: open " + missing + ": no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"check"}, tt.files...), strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("check %q exit code wrong. expected=%d, got=%d", tt.files, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("check %q stdout wrong. expected=%q, got=%q", tt.files, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("check %q stderr wrong. expected=%q, got=%q", tt.files, tt.expectedStderr, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"debugger"
+	"evaluator"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"lexer"
+	"object"
+	"os"
+	"parser"
+	"path/filepath"
+)
+
+const debugUsage = `usage: monkey debug file
+       monkey debug -dap
+
+Run file under a debugger reading commands from standard input, stopping
+before its first statement. Type help at the (debug) prompt for the commands.
+With -dap, serve a Debug Adapter Protocol client on standard input and output
+instead, the program to debug given by its launch request.
+
+flags:
+`
+
+// runDebug the debug subcommand
+func runDebug(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey debug", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, debugUsage)
+		flags.PrintDefaults()
+	}
+	dap := flags.Bool("dap", false, "serve the Debug Adapter Protocol")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	searchPath := filepath.SplitList(os.Getenv("MONKEYPATH"))
+
+	if *dap {
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return exitUsage
+		}
+
+		s := debugger.NewDAPServer(stdin, stdout)
+		s.SearchPath = searchPath
+		if err := s.Run(); err != nil {
+			fmt.Fprintf(stderr, "monkey debug: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path := flags.Arg(0)
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey debug: %s\n", err)
+		return exitUsage
+	}
+
+	p := parser.New(lexer.New(lexer.SkipShebang(string(src))))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(stderr, path, p)
+		return exitUsage
+	}
+
+	ev := evaluator.New()
+	ev.File = path
+	ev.SearchPath = searchPath
+	ev.Out = stdout
+	ev.MaxDepth = evaluator.DefaultMaxDepth
+
+	d := debugger.New(path, program, ev)
+	debugger.NewTerminal(d, string(src), stdin, stdout)
+
+	evaluated := d.Run(object.NewEnvironment(), true)
+	if err, ok := evaluated.(*object.Error); ok && err.Kind != object.Canceled {
+		io.WriteString(stderr, err.Traceback())
+		io.WriteString(stderr, "\n")
+		return exitRuntimeError
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDebug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	src := "#!/usr/bin/env monkey\nlet f = fn(x) { x + true };\nputs(1);\nf(2)\n"
+	broken := filepath.Join(dir, "broken.mk")
+	missing := filepath.Join(dir, "missing.mk")
+	for path, src := range map[string]string{script: src, broken: "let = 1;"} {
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry := "stopped at " + script + ":2 in <main> (entry)\n>    2  let f = fn(x) { x + true };\n"
+	usage := debugUsage + "  -dap\n    \tserve the Debug Adapter Protocol\n"
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"debug", script}, "b f\nc\np x\nq\n", 0, entry + "(debug) breakpoint at function f\n(debug) 1\n" +
+			"stopped at " + script + ":2 in f (function breakpoint)\n>    2  let f = fn(x) { x + true };\n(debug) x = 2\n(debug) ", ""},
+		{[]string{"debug", script}, "c\n", exitRuntimeError, entry + "(debug) 1\n", "Traceback (most recent call last):\n" +
+			"  " + script + ":4:2, in <main>\n  " + script + ":2:19, in f\nERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"debug", script}, "", 0, entry + "(debug) \n", ""},
+		{[]string{"debug", broken}, "", exitUsage, "", broken + ":1:5: parse error: expected next token to be IDENT, got = instead\n" +
+			broken + ":1:5: parse error: no prefix parse function for = found\n"},
+		{[]string{"debug", missing}, "", exitUsage, "", "monkey debug: open " + missing + ": no such file or directory\n"},
+		{[]string{"debug"}, "", exitUsage, "", usage},
+		{[]string{"debug", "-dap", script}, "", exitUsage, "", usage},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("run(%q) stdout wrong. expected=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("run(%q) stderr wrong. expected=%q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+// TestRunDebugDAP checks debug -dap serves the protocol. The responses are
+// framed JSON, so stdout only has to contain the expected response
+func TestRunDebugDAP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	if err := ioutil.WriteFile(script, []byte("puts(1);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	launch := `{"seq":1,"type":"request","command":"launch","arguments":{"program":"` + script + `"}}`
+	stdin := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(launch), launch)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"debug", "-dap"}, strings.NewReader(stdin), &stdout, &stderr); code != 0 {
+		t.Errorf("debug -dap exit code wrong. expected=0, got=%d", code)
+	}
+	if expected := `"command":"launch","request_seq":1,"success":true`; !strings.Contains(stdout.String(), expected) {
+		t.Errorf("debug -dap stdout wrong. expected to contain %q, got=%q", expected, stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("debug -dap wrote to stderr: %q", stderr.String())
+	}
+}
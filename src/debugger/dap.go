@@ -0,0 +1,620 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"evaluator"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"lexer"
+	"net/textproto"
+	"object"
+	"parser"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// dapMessage request, response or event of the Debug Adapter Protocol
+type dapMessage struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+
+	// requests
+	Command   string          `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// responses
+	RequestSeq int    `json:"request_seq,omitempty"`
+	Success    *bool  `json:"success,omitempty"`
+	Message    string `json:"message,omitempty"`
+
+	// events
+	Event string `json:"event,omitempty"`
+
+	Body interface{} `json:"body,omitempty"`
+}
+
+// resumption how the program resumes after a stop
+type resumption struct {
+	action Action
+	err    error
+}
+
+// DAPServer a Debug Adapter Protocol server reading requests from in and
+// writing responses and events to out. It debugs one program, launched by
+// the client, in a single thread of id 1
+type DAPServer struct {
+	// SearchPath directories searched for imports not found next to the
+	// program
+	SearchPath []string
+
+	in *bufio.Reader
+
+	// writing guards out and seq, as events are sent by the goroutine
+	// running the program
+	writing sync.Mutex
+	out     io.Writer
+	seq     int
+
+	d           *Debugger
+	program     string
+	stopOnEntry bool
+	running     bool
+	done        chan struct{}
+	resume      chan resumption
+
+	// mu guards the stop the program is paused at, the values variable
+	// references of this stop refer to, and whether the program is being
+	// terminated
+	mu       sync.Mutex
+	stop     *Stop
+	refs     []interface{}
+	quitting bool
+}
+
+// NewDAPServer create a server talking over in and out
+func NewDAPServer(in io.Reader, out io.Writer) *DAPServer {
+	return &DAPServer{in: bufio.NewReader(in), out: out, resume: make(chan resumption, 1)}
+}
+
+// Run serve requests until the client disconnects or closes in. A program
+// still running is stopped
+func (s *DAPServer) Run() error {
+	defer s.terminate()
+
+	for {
+		req, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		h, ok := dapHandlers[req.Command]
+		if !ok {
+			s.respond(req, nil, fmt.Errorf("unsupported request %s", req.Command))
+			continue
+		}
+		body, err := h(s, req.Arguments)
+		s.respond(req, body, err)
+
+		switch {
+		case req.Command == "launch" && err == nil:
+			s.event("initialized", nil)
+		case req.Command == "disconnect":
+			return nil
+		}
+	}
+}
+
+// read a request framed by a Content-Length header
+func (s *DAPServer) read() (*dapMessage, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("bad Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	msg := &dapMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// write msg framed by a Content-Length header, numbering it
+func (s *DAPServer) write(msg *dapMessage) {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+
+	// names such as <main> are kept as they are
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(msg)
+	body := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// respond answer req with body, or err if not nil
+func (s *DAPServer) respond(req *dapMessage, body interface{}, err error) {
+	success := err == nil
+	resp := &dapMessage{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &success, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.write(resp)
+}
+
+// event send the client an event
+func (s *DAPServer) event(event string, body interface{}) {
+	s.write(&dapMessage{Type: "event", Event: event, Body: body})
+}
+
+// dapHandler answer a request, returning the body of the response
+type dapHandler func(s *DAPServer, args json.RawMessage) (interface{}, error)
+
+var dapHandlers map[string]dapHandler
+
+func init() {
+	dapHandlers = map[string]dapHandler{
+		"initialize":             (*DAPServer).initialize,
+		"launch":                 (*DAPServer).launch,
+		"setBreakpoints":         (*DAPServer).setBreakpoints,
+		"setFunctionBreakpoints": (*DAPServer).setFunctionBreakpoints,
+		"setExceptionBreakpoints": func(s *DAPServer, args json.RawMessage) (interface{}, error) {
+			return nil, nil
+		},
+		"configurationDone": (*DAPServer).configurationDone,
+		"threads":           (*DAPServer).threads,
+		"stackTrace":        (*DAPServer).stackTrace,
+		"scopes":            (*DAPServer).scopes,
+		"variables":         (*DAPServer).variables,
+		"evaluate":          (*DAPServer).evaluate,
+		"continue":          resumeWith(Continue),
+		"next":              resumeWith(StepOver),
+		"stepIn":            resumeWith(StepIn),
+		"stepOut":           resumeWith(StepOut),
+		"pause":             (*DAPServer).pause,
+		"terminate":         (*DAPServer).terminateRequest,
+		"disconnect":        (*DAPServer).terminateRequest,
+	}
+}
+
+// decodeArgs decode the arguments of a request into v
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *DAPServer) initialize(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"supportsConfigurationDoneRequest": true,
+		"supportsFunctionBreakpoints":      true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+func (s *DAPServer) launch(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if s.d != nil {
+		return nil, fmt.Errorf("a program is already launched")
+	}
+	if a.Program == "" {
+		return nil, fmt.Errorf("no program to launch")
+	}
+
+	src, err := ioutil.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(lexer.SkipShebang(string(src))))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse error: %s", p.Errors()[0])
+	}
+
+	ev := evaluator.New()
+	ev.File = a.Program
+	ev.SearchPath = s.SearchPath
+	ev.Out = &outputWriter{s}
+	ev.MaxDepth = evaluator.DefaultMaxDepth
+
+	s.program, s.stopOnEntry = a.Program, a.StopOnEntry
+	s.d = New(a.Program, program, ev)
+	s.d.Stop = s.paused
+	return nil, nil
+}
+
+// launched the debugger of the launched program, or an error if there is
+// none yet
+func (s *DAPServer) launched() (*Debugger, error) {
+	if s.d == nil {
+		return nil, fmt.Errorf("no program launched")
+	}
+	return s.d, nil
+}
+
+// dapSource a source file
+type dapSource struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+func (s *DAPServer) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []int
+	for _, b := range a.Breakpoints {
+		lines = append(lines, b.Line)
+	}
+	if !samePath(a.Source.Path, s.program) {
+		// breakpoints are only set in the program, not in its imports
+		lines = nil
+	} else {
+		lines = d.SetBreakpoints(lines)
+	}
+
+	breakpoints := []map[string]interface{}{}
+	for i, b := range a.Breakpoints {
+		bp := map[string]interface{}{"verified": false, "line": b.Line}
+		if lines != nil && lines[i] != 0 {
+			bp["verified"], bp["line"] = true, lines[i]
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// samePath report whether a and b name the same file
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func (s *DAPServer) setFunctionBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, b := range a.Breakpoints {
+		names = append(names, b.Name)
+	}
+	breakpoints := []map[string]interface{}{}
+	for _, found := range d.SetFunctionBreakpoints(names) {
+		breakpoints = append(breakpoints, map[string]interface{}{"verified": found})
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// configurationDone start the program, the client has set its breakpoints
+func (s *DAPServer) configurationDone(args json.RawMessage) (interface{}, error) {
+	d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+	if s.running {
+		return nil, nil
+	}
+
+	s.running = true
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		result := d.Run(object.NewEnvironment(), s.stopOnEntry)
+		if err, ok := result.(*object.Error); ok && err.Kind != object.Canceled {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": err.Traceback() + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+	return nil, nil
+}
+
+// paused the Stop of the debugger: tell the client the program stopped and
+// wait for it to resume it
+func (s *DAPServer) paused(stop *Stop) (Action, error) {
+	s.mu.Lock()
+	if s.quitting {
+		s.mu.Unlock()
+		return 0, ErrQuit
+	}
+	s.stop, s.refs = stop, nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{"reason": stop.Reason, "threadId": 1, "allThreadsStopped": true})
+	r := <-s.resume
+	return r.action, r.err
+}
+
+// stopped the stop the program is paused at, or an error if it is running
+func (s *DAPServer) stopped() (*Stop, error) {
+	if s.stop == nil {
+		return nil, fmt.Errorf("the program is not paused")
+	}
+	return s.stop, nil
+}
+
+// resumeWith a handler resuming the paused program the way action says
+func resumeWith(action Action) dapHandler {
+	return func(s *DAPServer, args json.RawMessage) (interface{}, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, err := s.stopped(); err != nil {
+			return nil, err
+		}
+		s.stop, s.refs = nil, nil
+		s.resume <- resumption{action: action}
+		return map[string]interface{}{"allThreadsContinued": true}, nil
+	}
+}
+
+func (s *DAPServer) pause(args json.RawMessage) (interface{}, error) {
+	d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+	d.Pause()
+	return nil, nil
+}
+
+func (s *DAPServer) terminateRequest(args json.RawMessage) (interface{}, error) {
+	s.terminate()
+	return nil, nil
+}
+
+// terminate stop the program if it is running, and wait for it
+func (s *DAPServer) terminate() {
+	if !s.running {
+		return
+	}
+
+	s.d.Quit()
+	s.mu.Lock()
+	s.quitting = true
+	if s.stop != nil {
+		s.stop, s.refs = nil, nil
+		s.resume <- resumption{err: ErrQuit}
+	}
+	s.mu.Unlock()
+	<-s.done
+	s.running = false
+}
+
+func (s *DAPServer) threads(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+	}, nil
+}
+
+func (s *DAPServer) stackTrace(args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := []map[string]interface{}{}
+	for i, f := range stop.Frames {
+		frame := map[string]interface{}{"id": i + 1, "name": f.Function, "line": f.Line, "column": f.Column}
+		if f.File != "" {
+			frame["source"] = dapSource{Name: filepath.Base(f.File), Path: f.File}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// frame the frame of id frameID of stop, the innermost for 0
+func frame(stop *Stop, frameID int) (Frame, error) {
+	if frameID == 0 {
+		frameID = 1
+	}
+	if frameID < 1 || frameID > len(stop.Frames) {
+		return Frame{}, fmt.Errorf("unknown frame %d", frameID)
+	}
+	return stop.Frames[frameID-1], nil
+}
+
+// reference the variables reference of v, an environment, array or hash, for
+// the client to ask for its variables
+func (s *DAPServer) reference(v interface{}) int {
+	s.refs = append(s.refs, v)
+	return len(s.refs)
+}
+
+// valueReference the variables reference of val, 0 unless it has elements
+func (s *DAPServer) valueReference(val object.Object) int {
+	switch val := val.(type) {
+	case *object.Array:
+		if len(val.Elements) != 0 {
+			return s.reference(val)
+		}
+	case *object.Hash:
+		if len(val.Pairs) != 0 {
+			return s.reference(val)
+		}
+	}
+	return 0
+}
+
+func (s *DAPServer) scopes(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	f, err := frame(stop, a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []map[string]interface{}{}
+	for _, scope := range Scopes(f.Env) {
+		scopes = append(scopes, map[string]interface{}{
+			"name":               scope.Name,
+			"variablesReference": s.reference(scope.Env),
+			"expensive":          false,
+		})
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *DAPServer) variables(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.stopped(); err != nil {
+		return nil, err
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+
+	var vars []Variable
+	switch v := s.refs[a.VariablesReference-1].(type) {
+	case *object.Environment:
+		vars = Variables(v)
+	case *object.Array:
+		for i, elem := range v.Elements {
+			vars = append(vars, Variable{Name: fmt.Sprintf("[%d]", i), Value: elem})
+		}
+	case *object.Hash:
+		for _, pair := range v.Pairs {
+			vars = append(vars, Variable{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	}
+
+	variables := []map[string]interface{}{}
+	for _, v := range vars {
+		variables = append(variables, map[string]interface{}{
+			"name":               v.Name,
+			"value":              v.Value.Inspect(),
+			"type":               string(v.Value.Type()),
+			"variablesReference": s.valueReference(v.Value),
+		})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// evaluate look up the value of a name in a frame, other expressions are
+// not evaluated as they could change the program
+func (s *DAPServer) evaluate(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	f, err := frame(stop, a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(a.Expression)
+	val, ok := Lookup(f.Env, name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not bound", name)
+	}
+	return map[string]interface{}{
+		"result":             val.Inspect(),
+		"type":               string(val.Type()),
+		"variablesReference": s.valueReference(val),
+	}, nil
+}
+
+// outputWriter send what the program prints to the client as output events
+type outputWriter struct {
+	s *DAPServer
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]interface{}{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
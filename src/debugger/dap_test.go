@@ -0,0 +1,270 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// client talks to a DAPServer over pipes
+type client struct {
+	t   *testing.T
+	in  io.WriteCloser
+	out *bufio.Reader
+	seq int
+	// events received, as event name and body
+	events []string
+}
+
+// clientMessage a message from the server, its body as sent
+type clientMessage struct {
+	dapMessage
+	Body json.RawMessage `json:"body"`
+}
+
+func newClient(t *testing.T) (*client, chan error) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewDAPServer(inR, outW).Run()
+		outW.Close()
+	}()
+	return &client{t: t, in: inW, out: bufio.NewReader(outR)}, done
+}
+
+// read the next message from the server
+func (c *client) read() *clientMessage {
+	c.t.Helper()
+
+	length := 0
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("reading header: %s", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		fmt.Sscanf(line, "Content-Length: %d", &length)
+	}
+	body := make([]byte, length)
+	io.ReadFull(c.out, body)
+
+	var msg clientMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("decoding %s: %s", body, err)
+	}
+	if msg.Type == "event" {
+		c.events = append(c.events, msg.Event+" "+string(msg.Body))
+	}
+	return &msg
+}
+
+// request send command and return the body of its response, or its error
+// message prefixed by error
+func (c *client) request(command string, args interface{}) string {
+	c.t.Helper()
+
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	for {
+		msg := c.read()
+		if msg.Type != "response" || msg.RequestSeq != c.seq {
+			continue
+		}
+		if !*msg.Success {
+			return "error " + msg.Message
+		}
+		return string(msg.Body)
+	}
+}
+
+// wait for event, returning its body
+func (c *client) wait(event string) string {
+	c.t.Helper()
+
+	for _, e := range c.events {
+		if strings.HasPrefix(e, event+" ") {
+			c.events = nil
+			return strings.TrimPrefix(e, event+" ")
+		}
+	}
+	for {
+		msg := c.read()
+		if msg.Type == "event" && msg.Event == event {
+			c.events = nil
+			return string(msg.Body)
+		}
+	}
+}
+
+// launch the program in a file and set breakpoints on lines
+func (c *client) launch(path string, stopOnEntry bool, lines ...int) string {
+	c.t.Helper()
+
+	c.request("initialize", map[string]interface{}{"adapterID": "monkey"})
+	c.request("launch", map[string]interface{}{"program": path, "stopOnEntry": stopOnEntry})
+	c.wait("initialized")
+
+	var breakpoints []map[string]int
+	for _, line := range lines {
+		breakpoints = append(breakpoints, map[string]int{"line": line})
+	}
+	return c.request("setBreakpoints", map[string]interface{}{
+		"source": map[string]string{"path": path}, "breakpoints": breakpoints,
+	})
+}
+
+// close the connection, reading what the server still sends, and return the
+// error Run returned
+func (c *client) close(done chan error) error {
+	c.in.Close()
+	ioutil.ReadAll(c.out)
+	return <-done
+}
+
+func writeProgram(t *testing.T, src string) (string, func()) {
+	dir, err := ioutil.TempDir("", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.mk")
+	ioutil.WriteFile(path, []byte(src), 0644)
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func expect(t *testing.T, what, expected, got string) {
+	t.Helper()
+	if got != expected {
+		t.Errorf("%s wrong.\nexpected=%s\ngot=%s", what, expected, got)
+	}
+}
+
+func TestDAPSession(t *testing.T) {
+	path, cleanup := writeProgram(t, "#!/usr/bin/env monkey\n"+program)
+	defer cleanup()
+
+	c, done := newClient(t)
+	expect(t, "breakpoints", `{"breakpoints":[{"line":4,"verified":true},{"line":9,"verified":false}]}`,
+		c.launch(path, false, 4, 9))
+	expect(t, "function breakpoints", `{"breakpoints":[{"verified":true}]}`,
+		c.request("setFunctionBreakpoints", map[string]interface{}{"breakpoints": []map[string]string{{"name": "add"}}}))
+	c.request("configurationDone", nil)
+
+	expect(t, "stop", `{"allThreadsStopped":true,"reason":"function breakpoint","threadId":1}`, c.wait("stopped"))
+	expect(t, "threads", `{"threads":[{"id":1,"name":"main"}]}`, c.request("threads", nil))
+	expect(t, "stack",
+		`{"stackFrames":[{"column":3,"id":1,"line":3,"name":"add","source":{"name":"test.mk","path":"`+path+`"}},`+
+			`{"column":16,"id":2,"line":7,"name":"<main>","source":{"name":"test.mk","path":"`+path+`"}}],"totalFrames":2}`,
+		c.request("stackTrace", map[string]int{"threadId": 1}))
+	expect(t, "scopes",
+		`{"scopes":[{"expensive":false,"name":"Locals","variablesReference":1},{"expensive":false,"name":"Globals","variablesReference":2}]}`,
+		c.request("scopes", map[string]int{"frameId": 1}))
+	expect(t, "locals",
+		`{"variables":[{"name":"a","type":"INTEGER","value":"1","variablesReference":0},{"name":"b","type":"INTEGER","value":"2","variablesReference":0}]}`,
+		c.request("variables", map[string]int{"variablesReference": 1}))
+	expect(t, "evaluate", `{"result":"[1, 2]","type":"ARRAY","variablesReference":3}`,
+		c.request("evaluate", map[string]interface{}{"expression": "xs", "frameId": 2}))
+	expect(t, "elements",
+		`{"variables":[{"name":"[0]","type":"INTEGER","value":"1","variablesReference":0},{"name":"[1]","type":"INTEGER","value":"2","variablesReference":0}]}`,
+		c.request("variables", map[string]int{"variablesReference": 3}))
+	expect(t, "evaluate unbound", "error sum is not bound",
+		c.request("evaluate", map[string]interface{}{"expression": "sum"}))
+
+	c.request("next", map[string]int{"threadId": 1})
+	// the breakpoint on the next statement is the reason to stop there
+	expect(t, "step", `{"allThreadsStopped":true,"reason":"breakpoint","threadId":1}`, c.wait("stopped"))
+	c.request("stepOut", map[string]int{"threadId": 1})
+	c.wait("stopped")
+	if stack := c.request("stackTrace", map[string]int{"threadId": 1}); !strings.Contains(stack, `"line":8,"name":"<main>"`) {
+		t.Errorf("stack after step out wrong. got=%s", stack)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	expect(t, "output", `{"category":"stdout","output":"3\n"}`, c.wait("output"))
+	expect(t, "exit", `{"exitCode":0}`, c.wait("exited"))
+	c.wait("terminated")
+	expect(t, "not paused", "error the program is not paused", c.request("stackTrace", nil))
+
+	c.request("disconnect", nil)
+	if err := c.close(done); err != nil {
+		t.Errorf("Run returned error: %s", err)
+	}
+}
+
+func TestDAPRuntimeError(t *testing.T) {
+	path, cleanup := writeProgram(t, "let f = fn() { 1 + true };\nf();\n")
+	defer cleanup()
+
+	c, done := newClient(t)
+	c.launch(path, false)
+	c.request("configurationDone", nil)
+
+	expect(t, "output",
+		`{"category":"stderr","output":"Traceback (most recent call last):\n  `+path+`:2:2, in <main>\n  `+path+`:1:18, in f\nERROR: type mismatch: INTEGER + BOOLEAN\n"}`,
+		c.wait("output"))
+	expect(t, "exit", `{"exitCode":1}`, c.wait("exited"))
+
+	if err := c.close(done); err != nil {
+		t.Errorf("Run returned error: %s", err)
+	}
+}
+
+func TestDAPTerminate(t *testing.T) {
+	path, cleanup := writeProgram(t, program)
+	defer cleanup()
+
+	c, done := newClient(t)
+	c.launch(path, true)
+	c.request("configurationDone", nil)
+	c.wait("stopped")
+
+	expect(t, "terminate", "", c.request("terminate", nil))
+	expect(t, "exit", `{"exitCode":0}`, c.wait("exited"))
+	if err := c.close(done); err != nil {
+		t.Errorf("Run returned error: %s", err)
+	}
+}
+
+func TestDAPErrors(t *testing.T) {
+	path, cleanup := writeProgram(t, "let = 1;")
+	defer cleanup()
+
+	c, done := newClient(t)
+	tests := []struct {
+		command  string
+		args     interface{}
+		expected string
+	}{
+		{"setBreakpoints", map[string]interface{}{}, "error no program launched"},
+		{"launch", map[string]interface{}{}, "error no program to launch"},
+		{"launch", map[string]interface{}{"program": path},
+			"error parse error: expected next token to be IDENT, got = instead"},
+		{"continue", map[string]interface{}{}, "error the program is not paused"},
+		{"restart", map[string]interface{}{}, "error unsupported request restart"},
+	}
+
+	for _, tt := range tests {
+		expect(t, tt.command, tt.expected, c.request(tt.command, tt.args))
+	}
+
+	if err := c.close(done); err != nil {
+		t.Errorf("Run returned error: %s", err)
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package debugger runs monkey programs under a debugger, which pauses them at
+// breakpoints and after steps so that their call stack and environments can
+// be inspected.
+//
+// A Debugger is driven by a front end: Terminal reads commands from a
+// terminal, DAPServer speaks the Debug Adapter Protocol to an editor.
+//
+// Programs pause before a statement is evaluated. Breakpoints are set on the
+// first statement of a line, or of the body of a function bound by let.
+// Stepping over a statement runs it and the calls it makes. A call in tail
+// position replaces the frame of the function making it, so stepping over
+// one stops in the function called.
+package debugger
+
+import (
+	"ast"
+	"errors"
+	"evaluator"
+	"object"
+	"sort"
+	"sync"
+	"token"
+)
+
+// Reasons a program pauses
+const (
+	ReasonEntry              = "entry"
+	ReasonBreakpoint         = "breakpoint"
+	ReasonFunctionBreakpoint = "function breakpoint"
+	ReasonStep               = "step"
+	ReasonPause              = "pause"
+)
+
+// Action how a paused program resumes
+type Action int
+
+const (
+	// Continue run until a breakpoint
+	Continue Action = iota
+	// StepIn stop at the next statement, in a function called if any
+	StepIn
+	// StepOver stop at the next statement of the current function or one it
+	// returns to
+	StepOver
+	// StepOut stop at the next statement of a function the current one
+	// returns to
+	StepOut
+)
+
+// ErrQuit the front end stopped the program
+var ErrQuit = errors.New("debugger quit")
+
+// Frame a call being evaluated when the program paused
+type Frame struct {
+	// Function name of the called function, <anonymous>, or <main> for the
+	// program itself
+	Function string
+	// File path of the program, empty in imported modules
+	File string
+	// Line and Column locate the statement the program paused at in the
+	// innermost frame, and the call being made in the others
+	Line   int
+	Column int
+	// Env environment the frame evaluates in
+	Env *object.Environment
+}
+
+// Stop why and where a program paused
+type Stop struct {
+	Reason string
+	// Frames innermost first
+	Frames []Frame
+}
+
+// Debugger runs a program, pausing it as its front end asks. It is the
+// evaluator.Debugger of the Evaluator running the program
+type Debugger struct {
+	// Stop is called when the program pauses, and resumes it as it returns,
+	// the way action says. An error stops the program. Stop is called by the
+	// goroutine running the program
+	Stop func(s *Stop) (action Action, err error)
+
+	file    string
+	program *ast.Program
+	ev      *evaluator.Evaluator
+
+	// statements those of the program, not of imported modules
+	statements map[ast.Statement]bool
+	// lines the first statement of each line
+	lines map[int]ast.Statement
+	// functions the names of the let bound functions, by the first
+	// statement of their body
+	functions map[ast.Statement]string
+
+	mu             sync.Mutex
+	breakLines     map[int]bool
+	breakFunctions map[string]bool
+	pause          bool
+	quit           bool
+
+	// what the program does next, how deep the calls were when it resumed
+	// and the statement and environment each frame is at
+	entry     bool
+	action    Action
+	stepDepth int
+	frames    []frameState
+}
+
+type frameState struct {
+	stmt ast.Statement
+	env  *object.Environment
+}
+
+// New create a debugger for program, parsed from file, to be run by ev
+func New(file string, program *ast.Program, ev *evaluator.Evaluator) *Debugger {
+	d := &Debugger{
+		file:           file,
+		program:        program,
+		ev:             ev,
+		statements:     map[ast.Statement]bool{},
+		lines:          map[int]ast.Statement{},
+		functions:      map[ast.Statement]string{},
+		breakLines:     map[int]bool{},
+		breakFunctions: map[string]bool{},
+	}
+
+	add := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			d.statements[stmt] = true
+			line := statementToken(stmt).Line
+			if _, ok := d.lines[line]; !ok {
+				d.lines[line] = stmt
+			}
+		}
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			add(node.Statements)
+		case *ast.BlockStatement:
+			add(node.Statements)
+		case *ast.FunctionLiteral:
+			if node.Name != "" && len(node.Body.Statements) != 0 {
+				d.functions[node.Body.Statements[0]] = node.Name
+			}
+		}
+		return true
+	})
+
+	ev.Debugger = d
+	return d
+}
+
+// Run evaluate the program in env, pausing before its first statement if
+// stopOnEntry is set
+func (d *Debugger) Run(env *object.Environment, stopOnEntry bool) object.Object {
+	d.entry = stopOnEntry
+	d.action = Continue
+	d.frames = nil
+	return d.ev.Eval(d.program, env)
+}
+
+// SetBreakpoints replace the line breakpoints with ones on lines. A line
+// without statements gets its breakpoint on the next line that has one. The
+// lines breakpoints ended up on are returned, 0 for those after the last
+// statement
+func (d *Debugger) SetBreakpoints(lines []int) []int {
+	known := make([]int, 0, len(d.lines))
+	for line := range d.lines {
+		known = append(known, line)
+	}
+	sort.Ints(known)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakLines = map[int]bool{}
+	actual := make([]int, len(lines))
+	for i, line := range lines {
+		j := sort.SearchInts(known, line)
+		if j == len(known) {
+			continue
+		}
+		actual[i] = known[j]
+		d.breakLines[known[j]] = true
+	}
+	return actual
+}
+
+// SetFunctionBreakpoints replace the function breakpoints with ones on the
+// functions named names, reporting for each whether the program binds a
+// function of that name
+func (d *Debugger) SetFunctionBreakpoints(names []string) []bool {
+	defined := map[string]bool{}
+	for _, name := range d.functions {
+		defined[name] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakFunctions = map[string]bool{}
+	found := make([]bool, len(names))
+	for i, name := range names {
+		d.breakFunctions[name] = true
+		found[i] = defined[name]
+	}
+	return found
+}
+
+// Pause the running program before its next statement. It may be called by
+// any goroutine
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Quit stop the running program before its next statement, Run returning an
+// error of kind object.Canceled. It may be called by any goroutine
+func (d *Debugger) Quit() {
+	d.mu.Lock()
+	d.quit = true
+	d.mu.Unlock()
+}
+
+// Statement pause the program before stmt if a breakpoint or step says so
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) error {
+	depth := len(d.ev.Stack())
+	for len(d.frames) <= depth {
+		d.frames = append(d.frames, frameState{})
+	}
+	d.frames = d.frames[:depth+1]
+	d.frames[depth] = frameState{stmt, env}
+
+	d.mu.Lock()
+	quit, pause := d.quit, d.pause
+	d.pause = false
+	breakLine := d.breakLines[statementToken(stmt).Line] && d.lines[statementToken(stmt).Line] == stmt
+	name, isFunction := d.functions[stmt]
+	breakFunction := isFunction && d.breakFunctions[name]
+	d.mu.Unlock()
+
+	if quit {
+		return ErrQuit
+	}
+
+	reason := ""
+	switch {
+	case d.entry:
+		reason = ReasonEntry
+		d.entry = false
+	case pause:
+		reason = ReasonPause
+	case breakLine:
+		reason = ReasonBreakpoint
+	case breakFunction:
+		reason = ReasonFunctionBreakpoint
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.stepDepth,
+		d.action == StepOut && depth < d.stepDepth:
+		reason = ReasonStep
+	default:
+		return nil
+	}
+
+	action, err := d.Stop(&Stop{Reason: reason, Frames: d.stack()})
+	if err != nil {
+		return err
+	}
+	d.action, d.stepDepth = action, depth
+	return nil
+}
+
+// stack the frames of the paused program, innermost first
+func (d *Debugger) stack() []Frame {
+	calls := d.ev.Stack()
+	frames := make([]Frame, 0, len(calls)+1)
+
+	for i := len(calls); i >= 0; i-- {
+		f := Frame{Function: "<main>", Env: d.frames[i].env}
+		if i > 0 {
+			f.Function = calls[i-1].Function
+		}
+		if i == len(calls) {
+			tok := statementToken(d.frames[i].stmt)
+			f.Line, f.Column = tok.Line, tok.Column
+		} else {
+			f.Line, f.Column = calls[i].Line, calls[i].Column
+		}
+		if d.statements[d.frames[i].stmt] {
+			f.File = d.file
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// statementToken the first token of stmt
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ThrowStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+// Scope an environment a frame sees names in
+type Scope struct {
+	// Name Locals for the frame of a call or catch block, Closure for those
+	// of the functions it is nested in, Globals for the outermost
+	Name string
+	Env  *object.Environment
+}
+
+// Scopes the chain of environments env is enclosed by, innermost first
+func Scopes(env *object.Environment) []Scope {
+	var scopes []Scope
+	for e := env; e != nil; e = e.Outer() {
+		name := "Closure"
+		switch {
+		case e.Outer() == nil:
+			name = "Globals"
+		case e == env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, Env: e})
+	}
+	return scopes
+}
+
+// Variable a name bound in an environment
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Variables the names bound directly in env, sorted
+func Variables(env *object.Environment) []Variable {
+	var vars []Variable
+	for _, name := range env.Names() {
+		val, _ := env.Local(name)
+		vars = append(vars, Variable{Name: name, Value: val})
+	}
+	return vars
+}
+
+// Lookup the value name has in env or the environments enclosing it
+func Lookup(env *object.Environment, name string) (object.Object, bool) {
+	for e := env; e != nil; e = e.Outer() {
+		if val, ok := e.Local(name); ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
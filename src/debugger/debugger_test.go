@@ -0,0 +1,205 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package debugger
+
+import (
+	"bytes"
+	"evaluator"
+	"fmt"
+	"lexer"
+	"object"
+	"parser"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let xs = [1, 2];
+let total = add(xs[0], xs[1]);
+puts(total);
+`
+
+func newDebugger(t *testing.T, out *bytes.Buffer) *Debugger {
+	t.Helper()
+
+	p := parser.New(lexer.New(program))
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	ev := evaluator.New()
+	ev.Out = out
+	return New("test.mk", parsed, ev)
+}
+
+func TestStops(t *testing.T) {
+	tests := []struct {
+		stopOnEntry bool
+		lines       []int
+		functions   []string
+		actions     []Action
+		expected    string
+	}{
+		{true, nil, nil, []Action{StepIn, StepIn, StepIn, StepIn, StepIn, StepIn},
+			"entry <main>:1, step <main>:5, step <main>:6, step add:2, step add:3, step <main>:7"},
+		{true, nil, nil, []Action{StepOver, StepOver, StepOver, StepOver},
+			"entry <main>:1, step <main>:5, step <main>:6, step <main>:7"},
+		{false, []int{2}, nil, []Action{StepOut, Continue},
+			"breakpoint add:2, step <main>:7"},
+		{false, []int{3}, nil, []Action{StepOver, Continue},
+			"breakpoint add:3, step <main>:7"},
+		{false, nil, []string{"add"}, []Action{Continue},
+			"function breakpoint add:2"},
+		// the breakpoint moves from the end of the function to the next line
+		{false, []int{4}, nil, []Action{Continue}, "breakpoint <main>:5"},
+		{false, []int{9}, nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		d := newDebugger(t, &out)
+		d.SetBreakpoints(tt.lines)
+		d.SetFunctionBreakpoints(tt.functions)
+
+		var stops []string
+		d.Stop = func(s *Stop) (Action, error) {
+			stops = append(stops, fmt.Sprintf("%s %s:%d", s.Reason, s.Frames[0].Function, s.Frames[0].Line))
+			if len(stops) > len(tt.actions) {
+				return 0, ErrQuit
+			}
+			return tt.actions[len(stops)-1], nil
+		}
+
+		result := d.Run(object.NewEnvironment(), tt.stopOnEntry)
+		if err, ok := result.(*object.Error); ok {
+			t.Errorf("program failed: %s", err.Message)
+		}
+		if got := strings.Join(stops, ", "); got != tt.expected {
+			t.Errorf("stops wrong.\nexpected=%q\ngot=%q", tt.expected, got)
+		}
+		if out.String() != "3\n" {
+			t.Errorf("output wrong. expected=%q, got=%q", "3\n", out.String())
+		}
+	}
+}
+
+func TestSetBreakpoints(t *testing.T) {
+	d := newDebugger(t, &bytes.Buffer{})
+
+	lines := d.SetBreakpoints([]int{1, 4, 6, 8})
+	if fmt.Sprint(lines) != "[1 5 6 0]" {
+		t.Errorf("breakpoint lines wrong. expected=[1 5 6 0], got=%v", lines)
+	}
+
+	found := d.SetFunctionBreakpoints([]string{"add", "sum", "puts"})
+	if fmt.Sprint(found) != "[true false false]" {
+		t.Errorf("function breakpoints wrong. expected=[true false false], got=%v", found)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	d := newDebugger(t, &bytes.Buffer{})
+	d.SetBreakpoints([]int{3})
+
+	var stop *Stop
+	d.Stop = func(s *Stop) (Action, error) {
+		stop = s
+		return 0, ErrQuit
+	}
+
+	result := d.Run(object.NewEnvironment(), false)
+	err, ok := result.(*object.Error)
+	if !ok || err.Kind != object.Canceled || err.Message != "evaluation canceled: debugger quit" {
+		t.Fatalf("quitting result wrong. got=%s", result.Inspect())
+	}
+
+	var frames []string
+	for _, f := range stop.Frames {
+		frames = append(frames, fmt.Sprintf("%s %s:%d:%d", f.Function, f.File, f.Line, f.Column))
+	}
+	if got := strings.Join(frames, ", "); got != "add test.mk:3:3, <main> test.mk:6:16" {
+		t.Errorf("frames wrong. got=%q", got)
+	}
+
+	var scopes []string
+	for _, scope := range Scopes(stop.Frames[0].Env) {
+		var vars []string
+		for _, v := range Variables(scope.Env) {
+			vars = append(vars, v.Name+"="+v.Value.Inspect())
+		}
+		scopes = append(scopes, scope.Name+" "+strings.Join(vars, " "))
+	}
+	expected := "Locals a=1 b=2 sum=3, Globals add=" + stopValue(t, stop, "add") + " xs=[1, 2]"
+	if got := strings.Join(scopes, ", "); got != expected {
+		t.Errorf("scopes wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	if _, ok := Lookup(stop.Frames[1].Env, "a"); ok {
+		t.Errorf("a is bound in the frame of the program")
+	}
+}
+
+// stopValue the value name is bound to in the innermost frame of s
+func stopValue(t *testing.T, s *Stop, name string) string {
+	t.Helper()
+
+	val, ok := Lookup(s.Frames[0].Env, name)
+	if !ok {
+		t.Fatalf("%s is not bound", name)
+	}
+	return val.Inspect()
+}
+
+func TestTerminal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"c\n", []string{"stopped at test.mk:1 in <main> (entry)\n>    1  let add = fn(a, b) {\n(debug) 3\n"}},
+		{"b add\nb 4\nb\nc\nc\nbt\np sum\np a\nframe 1\np a\nq\n", []string{
+			"breakpoint at function add\n",
+			"breakpoint at line 5\n",
+			"breakpoint at 5\nbreakpoint at add\n",
+			"stopped at test.mk:5 in <main> (breakpoint)\n",
+			"stopped at test.mk:2 in add (function breakpoint)\n",
+			"* 0  add at test.mk:2\n  1  <main> at test.mk:6\n",
+			"sum is not bound\n",
+			"a = 1\n",
+			"1  <main> at test.mk:6\n>    6  let total = add(xs[0], xs[1]);\n",
+			"a is not bound\n",
+		}},
+		{"n\n\ns\n\nenv\nl\n", []string{
+			"stopped at test.mk:6 in <main> (step)\n",
+			"stopped at test.mk:3 in add (step)\n",
+			"Locals:\n  a = 1\n  b = 2\n  sum = 3\nGlobals:\n",
+			"     1  let add = fn(a, b) {\n     2    let sum = a + b;\n>    3    sum\n     4  };\n     5  let xs = [1, 2];\n     6  let total = add(xs[0], xs[1]);\n",
+		}},
+		{"b 3\nd 3\nd 3\nb 20\nb nope\nframe 5\nwhat\n", []string{
+			"breakpoint at line 3\n",
+			"no breakpoint at 3\n",
+			"no statement at or after line 20\n",
+			"no function nope\n",
+			"usage: frame <0-0>\n",
+			"unknown command what, type help for a list\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		d := newDebugger(t, &out)
+		NewTerminal(d, program, strings.NewReader(tt.input), &out)
+		d.Run(object.NewEnvironment(), true)
+
+		for _, expected := range tt.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("output of %q does not contain %q. got=\n%s", tt.input, expected, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// terminalHelp the commands of Terminal
+const terminalHelp = `commands:
+  c, continue        run until a breakpoint
+  s, step            step to the next statement, into calls
+  n, next            step over calls to the next statement
+  o, out             step out of the current function
+  b, break [where]   set a breakpoint on a line or function, list them
+  d, delete <where>  delete the breakpoint on a line or function
+  bt, stack          print the call stack
+  f, frame <n>       select frame n of the call stack
+  e, env             print the environments of the selected frame
+  p, print <name>    print the value of name in the selected frame
+  l, list            print the source around the selected frame
+  q, quit            stop the program
+  h, help            print this help
+An empty line repeats the last command.
+`
+
+// Terminal a command line front end of a Debugger, reading commands from in
+// and writing to out
+type Terminal struct {
+	d      *Debugger
+	in     *bufio.Scanner
+	out    io.Writer
+	source []string
+
+	lines     map[int]bool
+	functions map[string]bool
+
+	stop     *Stop
+	frame    int
+	lastLine string
+}
+
+// NewTerminal create a terminal front end of d, for a program whose source
+// is source
+func NewTerminal(d *Debugger, source string, in io.Reader, out io.Writer) *Terminal {
+	t := &Terminal{
+		d:         d,
+		in:        bufio.NewScanner(in),
+		out:       out,
+		source:    strings.Split(source, "\n"),
+		lines:     map[int]bool{},
+		functions: map[string]bool{},
+	}
+	d.Stop = t.pause
+	return t
+}
+
+// pause show where the program stopped and read commands until one resumes
+// it. The end of the input quits
+func (t *Terminal) pause(s *Stop) (Action, error) {
+	t.stop, t.frame = s, 0
+
+	f := s.Frames[0]
+	fmt.Fprintf(t.out, "stopped at %s in %s (%s)\n", t.location(f), f.Function, s.Reason)
+	t.printLine(f, f.Line)
+
+	for {
+		fmt.Fprint(t.out, "(debug) ")
+		if !t.in.Scan() {
+			fmt.Fprintln(t.out)
+			return 0, ErrQuit
+		}
+
+		line := strings.TrimSpace(t.in.Text())
+		if line == "" {
+			line = t.lastLine
+		}
+		t.lastLine = line
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+
+		switch cmd {
+		case "c", "continue":
+			return Continue, nil
+		case "s", "step":
+			return StepIn, nil
+		case "n", "next":
+			return StepOver, nil
+		case "o", "out":
+			return StepOut, nil
+		case "q", "quit":
+			return 0, ErrQuit
+		case "b", "break":
+			t.commandBreak(args)
+		case "d", "delete":
+			t.commandDelete(args)
+		case "bt", "stack":
+			t.commandStack()
+		case "f", "frame":
+			t.commandFrame(args)
+		case "e", "env":
+			t.commandEnv()
+		case "p", "print":
+			t.commandPrint(args)
+		case "l", "list":
+			t.commandList()
+		case "h", "help":
+			io.WriteString(t.out, terminalHelp)
+		default:
+			fmt.Fprintf(t.out, "unknown command %s, type help for a list\n", cmd)
+		}
+	}
+}
+
+// location file:line of f, or the line alone in an imported module
+func (t *Terminal) location(f Frame) string {
+	if f.File == "" {
+		return fmt.Sprintf("line %d", f.Line)
+	}
+	return fmt.Sprintf("%s:%d", f.File, f.Line)
+}
+
+// printLine print line of the source, if f is in the program
+func (t *Terminal) printLine(f Frame, line int) {
+	if f.File == "" || line < 1 || line > len(t.source) {
+		return
+	}
+	marker := " "
+	if line == f.Line {
+		marker = ">"
+	}
+	fmt.Fprintf(t.out, "%s %4d  %s\n", marker, line, t.source[line-1])
+}
+
+func (t *Terminal) commandBreak(args []string) {
+	if len(args) == 0 {
+		var where []string
+		for line := range t.lines {
+			where = append(where, strconv.Itoa(line))
+		}
+		sort.Slice(where, func(i, j int) bool {
+			a, _ := strconv.Atoi(where[i])
+			b, _ := strconv.Atoi(where[j])
+			return a < b
+		})
+		for name := range t.functions {
+			where = append(where, name)
+		}
+		sort.Strings(where[len(t.lines):])
+		if len(where) == 0 {
+			fmt.Fprintln(t.out, "no breakpoints")
+		}
+		for _, w := range where {
+			fmt.Fprintf(t.out, "breakpoint at %s\n", w)
+		}
+		return
+	}
+
+	if line, err := strconv.Atoi(args[0]); err == nil {
+		actual := t.d.SetBreakpoints(append(t.lineList(), line))
+		if at := actual[len(actual)-1]; at != 0 {
+			t.lines[at] = true
+			fmt.Fprintf(t.out, "breakpoint at line %d\n", at)
+		} else {
+			fmt.Fprintf(t.out, "no statement at or after line %d\n", line)
+		}
+		t.d.SetBreakpoints(t.lineList())
+		return
+	}
+
+	found := t.d.SetFunctionBreakpoints(append(t.functionList(), args[0]))
+	if found[len(found)-1] {
+		t.functions[args[0]] = true
+		fmt.Fprintf(t.out, "breakpoint at function %s\n", args[0])
+	} else {
+		fmt.Fprintf(t.out, "no function %s\n", args[0])
+	}
+	t.d.SetFunctionBreakpoints(t.functionList())
+}
+
+func (t *Terminal) commandDelete(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(t.out, "usage: delete <line | function>")
+		return
+	}
+
+	if line, err := strconv.Atoi(args[0]); err == nil && t.lines[line] {
+		delete(t.lines, line)
+		t.d.SetBreakpoints(t.lineList())
+	} else if t.functions[args[0]] {
+		delete(t.functions, args[0])
+		t.d.SetFunctionBreakpoints(t.functionList())
+	} else {
+		fmt.Fprintf(t.out, "no breakpoint at %s\n", args[0])
+	}
+}
+
+func (t *Terminal) lineList() []int {
+	lines := []int{}
+	for line := range t.lines {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (t *Terminal) functionList() []string {
+	names := []string{}
+	for name := range t.functions {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (t *Terminal) commandStack() {
+	for i, f := range t.stop.Frames {
+		marker := " "
+		if i == t.frame {
+			marker = "*"
+		}
+		fmt.Fprintf(t.out, "%s %d  %s at %s\n", marker, i, f.Function, t.location(f))
+	}
+}
+
+func (t *Terminal) commandFrame(args []string) {
+	n := -1
+	if len(args) == 1 {
+		n, _ = strconv.Atoi(args[0])
+	}
+	if n < 0 || n >= len(t.stop.Frames) {
+		fmt.Fprintf(t.out, "usage: frame <0-%d>\n", len(t.stop.Frames)-1)
+		return
+	}
+
+	t.frame = n
+	f := t.stop.Frames[n]
+	fmt.Fprintf(t.out, "%d  %s at %s\n", n, f.Function, t.location(f))
+	t.printLine(f, f.Line)
+}
+
+func (t *Terminal) commandEnv() {
+	env := t.stop.Frames[t.frame].Env
+	if env == nil {
+		return
+	}
+	for _, scope := range Scopes(env) {
+		fmt.Fprintf(t.out, "%s:\n", scope.Name)
+		for _, v := range Variables(scope.Env) {
+			fmt.Fprintf(t.out, "  %s = %s\n", v.Name, v.Value.Inspect())
+		}
+	}
+}
+
+func (t *Terminal) commandPrint(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(t.out, "usage: print <name>")
+		return
+	}
+
+	val, ok := Lookup(t.stop.Frames[t.frame].Env, args[0])
+	if !ok {
+		fmt.Fprintf(t.out, "%s is not bound\n", args[0])
+		return
+	}
+	fmt.Fprintf(t.out, "%s = %s\n", args[0], val.Inspect())
+}
+
+func (t *Terminal) commandList() {
+	f := t.stop.Frames[t.frame]
+	if f.File == "" {
+		fmt.Fprintln(t.out, "no source for an imported module")
+		return
+	}
+	for line := f.Line - 3; line <= f.Line+3; line++ {
+		t.printLine(f, line)
+	}
+}
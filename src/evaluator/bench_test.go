@@ -0,0 +1,49 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"lexer"
+	"object"
+	"parser"
+	"testing"
+)
+
+func benchmarkEval(b *testing.B, input string) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err, ok := Eval(program, object.NewEnvironment()).(*object.Error); ok {
+			b.Fatal(err.Inspect())
+		}
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkEval(b, `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20);`)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkEval(b, `
+let adder = fn(x) { fn(y) { let z = x + y; z } };
+let loop = fn(i, acc) {
+	if (i == 0) { return acc; }
+	let add = adder(i);
+	loop(i - 1, add(acc))
+};
+loop(500, 0);`)
+}
+
+func BenchmarkArray(b *testing.B) {
+	benchmarkEval(b, `
+let build = fn(i, arr) { if (i == 0) { arr } else { build(i - 1, push(arr, i)) } };
+let sum = fn(arr, i, acc) { if (i == len(arr)) { acc } else { sum(arr, i + 1, acc + arr[i]) } };
+sum(build(500, []), 0, 0);`)
+}
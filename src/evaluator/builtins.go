@@ -7,112 +7,144 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
 	"object"
+	"os"
+	"sort"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
+// builtins the built-in functions, by name. Evaluators use their own, made by
+// newBuiltins with their memory accountant and output
+var builtins = newBuiltins(nil, os.Stdout)
+
+// newBuiltins create the built-in functions, charging what they allocate to
+// mem and printing to out
+func newBuiltins(mem *memory, out io.Writer) map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"len": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.String:
+					return object.NewInteger(int64(len(arg.Value)))
+				case *object.Array:
+					return object.NewInteger(int64(len(arg.Elements)))
+				default:
+					return newError("argument to `len` not supported, got %s", args[0].Type())
+				}
+			},
 		},
-	},
-
-	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.ARRAYOBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-
-			return NULL
-		},
-	},
-
-	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.ARRAYOBJ {
-				return newError("argument to `must` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-
-			return NULL
+
+		"first": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != object.ARRAYOBJ {
+					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+
+				return NULL
+			},
 		},
-	},
-
-	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments")
-			}
-			if args[0].Type() != object.ARRAYOBJ {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
-			}
-
-			return NULL
+
+		"last": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != object.ARRAYOBJ {
+					return newError("argument to `must` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length-1]
+				}
+
+				return NULL
+			},
 		},
-	},
 
-	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
-			}
-			if args[0].Type() != object.ARRAYOBJ {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-			}
+		"rest": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments")
+				}
+				if args[0].Type() != object.ARRAYOBJ {
+					return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					if err := mem.chargeArray(length - 1); err != nil {
+						return err
+					}
+					newElements := make([]object.Object, length-1, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &object.Array{Elements: newElements}
+				}
+
+				return NULL
+			},
+		},
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+		"push": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != object.ARRAYOBJ {
+					return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				pushed := arr.Push(args[1])
+
+				// only a new backing array is charged in full
+				size := 1
+				if len(arr.Elements) == 0 || &pushed.Elements[0] != &arr.Elements[0] {
+					size = cap(pushed.Elements)
+				}
+				if err := mem.chargeArray(size); err != nil {
+					return err
+				}
+
+				return pushed
+			},
+		},
 
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
+		"puts": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(out, arg.Inspect())
+				}
 
-			return &object.Array{Elements: newElements}
+				return NULL
+			},
 		},
-	},
 
-	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
+		"json_parse":     &object.Builtin{Fn: mem.jsonParse},
+		"json_stringify": &object.Builtin{Fn: mem.jsonStringify},
+	}
+}
 
-			return NULL
-		},
-	},
+// BuiltinNames list the names of the built-in functions, sorted
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
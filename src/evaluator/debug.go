@@ -0,0 +1,38 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"object"
+)
+
+// Debugger is told by an Evaluator of each statement it is about to evaluate,
+// and pauses the evaluation for as long as it takes to return
+type Debugger interface {
+	// Statement is called before stmt is evaluated in env. An error stops
+	// the evaluation with an error of kind object.Canceled
+	Statement(stmt ast.Statement, env *object.Environment) error
+}
+
+// debug tell the debugger, if any, that stmt is about to be evaluated
+func (e *Evaluator) debug(stmt ast.Statement, env *object.Environment) *object.Error {
+	if e.Debugger == nil {
+		return nil
+	}
+	if err := e.Debugger.Statement(stmt, env); err != nil {
+		return e.abort(object.Canceled, "evaluation canceled: %s", err)
+	}
+	return nil
+}
+
+// Stack the calls being evaluated, outermost first. Each frame records where
+// its function was called from
+func (e *Evaluator) Stack() []object.Frame {
+	stack := make([]object.Frame, len(e.frames))
+	copy(stack, e.frames)
+	return stack
+}
@@ -7,8 +7,12 @@ package evaluator
 
 import (
 	"ast"
+	"context"
 	"fmt"
+	"io"
 	"object"
+	"resolver"
+	"token"
 )
 
 var (
@@ -20,96 +24,216 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
-// Eval the eval enter method
+// Evaluator tree-walking interpreter state. An Evaluator keeps the monkey call
+// stack of the program it is running and the modules it has imported, and
+// must not be shared between goroutines
+type Evaluator struct {
+	// File path of the file being evaluated, used to resolve relative imports
+	File string
+	// SearchPath directories searched for imports not found next to File
+	SearchPath []string
+
+	// MaxSteps stops the evaluation after that many steps, 0 for no limit
+	MaxSteps int
+	// MaxDepth stops the evaluation when calls nest deeper, 0 for no limit.
+	// Tail calls do not nest. Without a limit, deep recursion overflows the
+	// Go stack, see DefaultMaxDepth
+	MaxDepth int
+	// MaxMemory bounds the approximate number of bytes allocated for strings,
+	// arrays and hashes, 0 for no limit. Allocating more raises an error
+	// monkey code may catch
+	MaxMemory int64
+	// Optimize programs with Optimize before evaluating them
+	Optimize bool
+	// Out receives what puts prints, standard output if nil. It is read once,
+	// when the Evaluator first evaluates something
+	Out io.Writer
+	// Debugger is told of each statement before it is evaluated, if not nil
+	Debugger Debugger
+
+	frames  []object.Frame
+	modules map[string]*object.Module
+	loading []string
+
+	ctxs     []context.Context
+	steps    int
+	aborted  *object.Error
+	mem      memory
+	builtins map[string]*object.Builtin
+}
+
+// New create new Evaluator instance
+func New() *Evaluator {
+	return &Evaluator{}
+}
+
+// Eval evaluate node with a fresh Evaluator
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// Eval the eval enter method. node is resolved first, and an identifier
+// bound neither in node, env nor the builtins is reported as an error before
+// anything is evaluated
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if !e.running() {
+		return e.EvalContext(context.Background(), node, env)
+	}
+
+	errs := resolver.Resolve(node, func(name string) bool {
+		if _, ok := env.Get(name); ok {
+			return true
+		}
+		_, ok := builtins[name]
+		return ok
+	})
+	if len(errs) > 0 {
+		tok := errs[0].Token
+		return &object.Error{Message: errs[0].Error(), File: e.File, Line: tok.Line, Column: tok.Column}
+	}
+
+	if program, ok := node.(*ast.Program); ok && e.Optimize {
+		Optimize(program)
+	}
+
+	return e.eval(node, env)
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return object.NewInteger(node.Value)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.errorAt(node.Token, evalPrefixExpression(node.Operator, right))
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		if err := e.chargeInfix(node.Operator, left, right); err != nil {
+			return e.errorAt(node.Token, err)
+		}
+		return e.errorAt(node.Token, evalInfixExpression(node.Operator, left, right))
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return e.errorAt(node.Token, &object.Error{Message: val.Inspect(), Value: val})
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if err := checkLet(node.Name, val); err != nil {
+			return e.errorAt(node.Name.Token, err)
+		}
+		if node.Name.Local {
+			env.SetSlot(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.errorAt(node.Token, e.evalIdentifier(node, env))
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: params,
+			ReturnType: node.ReturnType,
+			Env:        env,
+			Body:       body,
+			File:       e.File,
+			Locals:     node.Locals,
+		}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function := e.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return e.errorAt(node.Token, e.applyFunction(node.Token, function, args))
 	case *ast.StringLiteral:
+		if err := e.mem.chargeString(len(node.Value)); err != nil {
+			return e.errorAt(node.Token, err)
+		}
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
+		if err := e.mem.chargeArray(len(elements)); err != nil {
+			return e.errorAt(node.Token, err)
+		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return e.errorAt(node.Token, evalIndexExpression(left, index))
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.errorAt(node.Token, e.evalHashLiteral(node, env))
+	case *ast.ImportExpression:
+		return e.errorAt(node.Token, e.evalImportExpression(node))
+	case *ast.MemberExpression:
+		left := e.eval(node.Object, env)
+		if isError(left) {
+			return left
+		}
+		return e.errorAt(node.Property.Token, evalMemberExpression(left, node.Property.Value))
 	}
 
 	return nil
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, keyNode := range node.OrderedKeys() {
+		valueNode := node.Pairs[keyNode]
+
+		key := e.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -119,7 +243,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -128,6 +252,9 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
 
+	if err := e.mem.chargeHash(len(pairs)); err != nil {
+		return err
+	}
 	return &object.Hash{Pairs: pairs}
 }
 
@@ -137,11 +264,24 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASHOBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULEOBJ && index.Type() == object.STRINGOBJ:
+		return evalModuleMember(left.(*object.Module), index.(*object.String).Value)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func evalMemberExpression(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Module:
+		return evalModuleMember(left, name)
+	case *object.Hash:
+		return evalHashIndexExpression(left, &object.String{Value: name})
+	default:
+		return newError("member access not supported: %s", left.Type())
+	}
+}
+
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
@@ -160,7 +300,7 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	idx := index.(*object.Integer).Value()
 	max := int64(len(arrayObject.Elements) - 1)
 
 	if idx < 0 || idx > max {
@@ -170,11 +310,11 @@ func evalArrayIndexExpression(array object.Object, index object.Object) object.O
 	return arrayObject.Elements[idx]
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -184,12 +324,58 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(call token.Token, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		if err := checkArgs(fn, args); err != nil {
+			return err
+		}
+
+		if e.MaxDepth > 0 && len(e.frames) >= e.MaxDepth {
+			return e.abort(object.DepthLimit, "call depth limit of %d exceeded", e.MaxDepth)
+		}
+
+		// the body of fn is in the file defining it, which errors and relative
+		// imports inside it refer to
+		file := e.File
+		e.frames = append(e.frames, newFrame(call, fn, file))
+		e.File = fn.File
+		defer func() {
+			e.frames = e.frames[:len(e.frames)-1]
+			e.File = file
+		}()
+
+		// the value of a tail call is returned by every function that made
+		// one, so it must have the return types of all of them
+		var returns returnChecks
+
+		// a tail call replaces the frame of the function making it
+		for {
+			returns.add(fn)
+			extendedEnv := extendFunctionEnv(fn, args)
+			evaluated := unwrapReturnValue(e.evalTailStatements(fn.Body, extendedEnv, true))
+
+			tc, ok := evaluated.(*tailCall)
+			if !ok {
+				if isError(evaluated) {
+					return evaluated
+				}
+				return e.checkReturn(returns, evaluated)
+			}
+			if len(tc.args) != len(tc.fn.Parameters) {
+				return e.errorAt(tc.call, newError("wrong number of arguments. got=%d, want=%d", len(tc.args), len(tc.fn.Parameters)))
+			}
+			if err := checkArgs(tc.fn, tc.args); err != nil {
+				return e.errorAt(tc.call, err)
+			}
+
+			fn, args = tc.fn, tc.args
+			e.frames[len(e.frames)-1] = newFrame(tc.call, fn, e.File)
+			e.File = fn.File
+		}
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
@@ -197,11 +383,30 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+func newFrame(call token.Token, fn *object.Function, file string) object.Frame {
+	return object.Frame{Function: functionName(fn), File: file, Line: call.Line, Column: call.Column}
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// Apply call fn, a function or builtin, with args
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	if !e.running() {
+		return e.ApplyContext(context.Background(), fn, args...)
+	}
+	return e.applyFunction(token.Token{}, fn, args)
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewFrame(fn.Env, fn.Locals)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		env.SetSlot(param.Slot, args[paramIdx])
 	}
 
 	return env
@@ -215,23 +420,42 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Local {
+		if val := env.GetSlot(node.Depth, node.Slot); val != nil {
+			return val
+		}
+
+		// a local read before its let statement ran is the name bound outside
+		// of its frame, if any
+		frame := env
+		for depth := node.Depth; depth > 0; depth-- {
+			frame = frame.Outer()
+		}
+		for outer := frame.Outer(); outer != nil; outer = outer.Outer() {
+			if val, ok := outer.Local(node.Value); ok {
+				return val
+			}
+		}
+	} else if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		if err := e.debug(statement, env); err != nil {
+			return err
+		}
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -244,11 +468,14 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if err := e.debug(statement, env); err != nil {
+			return err
+		}
+		result = e.eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -293,18 +520,22 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGEROBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return object.NewInteger(-right.Value())
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGEROBJ && right.Type() == object.INTEGEROBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -329,18 +560,66 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 }
 
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftVal := left.(*object.Integer).Value()
+	rightVal := right.(*object.Integer).Value()
+
+	switch operator {
+	case "+":
+		return object.NewInteger(leftVal + rightVal)
+	case "-":
+		return object.NewInteger(leftVal - rightVal)
+	case "*":
+		return object.NewInteger(leftVal * rightVal)
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return object.NewInteger(leftVal / rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// isNumber report whether obj is an integer or a float
+func isNumber(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.INTEGEROBJ || t == object.FLOATOBJ
+}
+
+// toFloat the value of the number obj as a float
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value())
+	}
+	return obj.(*object.Float).Value
+}
+
+// evalFloatInfixExpression apply operator to two numbers, at least one a
+// float, converting the integer if there is one
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -354,21 +633,73 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.eval(te.Block, env)
+	if e.aborted != nil {
+		return e.aborted
+	}
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchEnv := object.NewFrame(env, te.CatchLocals)
+		catchEnv.SetSlot(te.Param.Slot, caughtValue(err))
+		result = e.eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		// an error or return inside finally overrides the outcome of the try
+		finally := e.eval(te.Finally, env)
+		if finally != nil {
+			ft := finally.Type()
+			if ft == object.RETURNVALUEOBJ || ft == object.ERROROBJ {
+				return finally
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// caughtValue converts an error into the value bound by catch: the thrown
+// object itself, or a hash describing a runtime error
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	set := func(key string, value object.Object) {
+		k := &object.String{Value: key}
+		pairs[k.HashKey()] = object.HashPair{Key: k, Value: value}
+	}
+
+	set("message", &object.String{Value: err.Message})
+	if err.Line > 0 {
+		set("line", object.NewInteger(int64(err.Line)))
+		set("column", object.NewInteger(int64(err.Column)))
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -386,6 +717,20 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// errorAt records the position of tok and a snapshot of the call stack on obj
+// if it is an error that has no position yet, so errors report the innermost
+// expression that raised them
+func (e *Evaluator) errorAt(tok token.Token, obj object.Object) object.Object {
+	if err, ok := obj.(*object.Error); ok && err.Line == 0 {
+		err.File = e.File
+		err.Line = tok.Line
+		err.Column = tok.Column
+		err.Stack = make([]object.Frame, len(e.frames))
+		copy(err.Stack, e.frames)
+	}
+	return obj
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROROBJ
@@ -6,10 +6,18 @@
 package evaluator
 
 import (
+	"ast"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"lexer"
 	"object"
 	"parser"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -196,6 +204,10 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -204,6 +216,10 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"fn(a, b) { a }(1)",
+			"wrong number of arguments. got=1, want=2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -287,6 +303,31 @@ func TestClosure(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestLocalReadBeforeLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", 3},
+		{"let f = fn(x) { fn() { let y = x; let x = 2; y } }; f(5)()", 5},
+		{"let f = fn() { let y = len; let len = 1; y([1, 2]) }; f()", 2},
+		{"let f = fn() { if (false) { let x = 1 }; x }; f()", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong result for %q. expected error %q, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -345,6 +386,85 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		json     string
+		input    string
+		expected string
+	}{
+		{`42`, `json_parse(src)`, "42"},
+		{`-1.5e3`, `json_parse(src)`, "-1500"},
+		{`0.25`, `json_parse(src)`, "0.25"},
+		{`"a\u0041"`, `json_parse(src)`, "aA"},
+		{`[1, true, null, "x"]`, `json_parse(src)`, "[1, true, null, x]"},
+		{`{"a": {"b": [1]}}`, `json_parse(src)["a"]["b"][0]`, "1"},
+		{`null`, `json_parse(src)`, "null"},
+		{`{"k": [0.5, "s", false]}`, `json_stringify(json_parse(src))`, `{"k":[0.5,"s",false]}`},
+		{"", `json_stringify({"b": [1, 2], "a": "x", "c": {"d": true}})`, `{"a":"x","b":[1,2],"c":{"d":true}}`},
+		{"", `json_stringify({1: "x", true: "<&>"})`, `{"1":"x","true":"<&>"}`},
+		{"", `json_stringify([1, [2]], 2)`, "[\n  1,\n  [\n    2\n  ]\n]"},
+		{"", "json_stringify({\"a\": 1}, \"\t\")", "{\n\t\"a\": 1\n}"},
+		{"", `json_stringify({"a": 1}, "ab")`, "ERROR: indent for `json_stringify` must be at most 10 spaces or tabs, got \"ab\""},
+		{"", `json_stringify({"a": 1}, "           ")`, "ERROR: indent for `json_stringify` must be at most 10 spaces or tabs, got \"           \""},
+		{"", `json_stringify(first([]))`, "null"},
+		{`{`, `json_parse(src)`, "ERROR: json_parse: unexpected EOF"},
+		{`1 2`, `json_parse(src)`, "ERROR: json_parse: unexpected data after top-level value"},
+		{"", `json_parse(1)`, "ERROR: argument to `json_parse` must be STRING, got INTEGER"},
+		{"", `json_stringify(fn(x) { x })`, "ERROR: json_stringify: unsupported value FUNCTION"},
+		{"", `json_stringify({"f": len})`, "ERROR: json_stringify: unsupported value BUILTIN"},
+		{"", `json_stringify(1, [])`, "ERROR: indent for `json_stringify` must be INTEGER or STRING, got ARRAY"},
+		{"", `json_stringify(1, -1)`, "ERROR: indent for `json_stringify` must be between 0 and 10, got -1"},
+		{"", `json_stringify(1, 200000)`, "ERROR: indent for `json_stringify` must be between 0 and 10, got 200000"},
+		{"", `json_stringify({1: "a", "1": "b"})`, `ERROR: json_stringify: duplicate key "1"`},
+		{"", `json_stringify({"k": {true: 1, "true": 2}})`, `ERROR: json_stringify: duplicate key "true"`},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.Set("src", &object.String{Value: tt.json})
+
+		evaluated := Eval(program, env)
+		if evaluated == nil {
+			t.Errorf("no object returned for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s with src=%s. expected=%q, got=%q", tt.input, tt.json, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = json_parse("1.5"); f + 1`, "2.5"},
+		{`let f = json_parse("1.5"); 1 - f`, "-0.5"},
+		{`let f = json_parse("1.5"); f * f`, "2.25"},
+		{`let f = json_parse("1.5"); 3 / f`, "2"},
+		{`let f = json_parse("1.5"); -f`, "-1.5"},
+		{`let f = json_parse("1.5"); f < 2`, "true"},
+		{`let f = json_parse("1.5"); 1 > f`, "false"},
+		{`json_parse("1.5") == json_parse("1.5")`, "true"},
+		{`json_parse("1.5") != json_parse("2.5")`, "true"},
+		{`json_parse("1.5") * 2 == 3`, "true"},
+		{`let f = json_parse("1.5"); {f: "a"}[json_parse("1.5")]`, "a"},
+		{`let f = json_parse("0.5"); {1: "a"}[f + f]`, "a"},
+		{`let f = json_parse("1.5"); f / 0`, "ERROR: division by zero"},
+		{`let f = json_parse("1.5"); f + "a"`, "ERROR: type mismatch: FLOAT + STRING"},
+		{`let f = json_parse("1.5"); !f`, "false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestArrayLiteral(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -443,7 +563,7 @@ func TestHashLiteral(t *testing.T) {
 		(&object.String{Value: "one"}).HashKey():   1,
 		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
+		object.NewInteger(4).HashKey():             4,
 		TRUE.HashKey():                             5,
 		FALSE.HashKey():                            6,
 	}
@@ -507,6 +627,530 @@ func TestHashIndexExpression(t *testing.T) {
 	}
 }
 
+func TestTryCatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw 5; 1 } catch (e) { e * 2 }`, 10},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		// unresolved identifiers are reported before anything runs
+		{`try { foobar } catch (e) { e["message"] }`, "identifier not found: foobar"},
+		{`try { 1 } catch (e) { foobar }`, "identifier not found: foobar"},
+		{`try { 1 + true } catch (e) { e["column"] }`, 9},
+		{`try { throw "boom" } catch (e) { e }`, "boom"},
+		{`try { throw [1, 2] } catch (e) { len(e) }`, 2},
+		{`let f = fn() { throw 3 }; try { f() } catch (e) { e }`, 3},
+		{`let x = 1; try { throw 1 } catch (e) { 2 } finally { let x = 5 }; x`, 5},
+		{`try { 1 } finally { 2 }`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn() { try { throw 1 } catch (e) { return 7 }; 8 }; f()`, 7},
+		{`try { try { throw 1 } finally { 2 } } catch (e) { e + 10 }`, 11},
+		{`try { throw 1 } catch (e) { throw e + 1 }`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			var got string
+			switch obj := evaluated.(type) {
+			case *object.String:
+				got = obj.Value
+			case *object.Error:
+				got = obj.Message
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if got != expected {
+				t.Errorf("wrong value. expected=%q, got=%q", expected, got)
+			}
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	evaluated := testEval("let x = 1;\nthrow {\"code\": 42};\nx")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if _, ok := errObj.Value.(*object.Hash); !ok {
+		t.Errorf("errObj.Value is not Hash. got=%T (%+v)", errObj.Value, errObj.Value)
+	}
+
+	if errObj.Line != 2 || errObj.Column != 1 {
+		t.Errorf("wrong error position. expected=2:1, got=%d:%d", errObj.Line, errObj.Column)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = fn(x) {
+	x + true
+};
+let outer = fn(y) { inner(y) + 1 };
+fn() { outer(1) + 1 }();`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "<anonymous>", Line: 5, Column: 22},
+		{Function: "outer", Line: 5, Column: 13},
+		{Function: "inner", Line: 4, Column: 26},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. expected=%d, got=%d (%+v)", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+
+	for i, frame := range expected {
+		if errObj.Stack[i] != frame {
+			t.Errorf("stack[%d] wrong. expected=%+v, got=%+v", i, frame, errObj.Stack[i])
+		}
+	}
+
+	if errObj.Line != 2 || errObj.Column != 4 {
+		t.Errorf("wrong error position. expected=2:4, got=%d:%d", errObj.Line, errObj.Column)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + 1) } }; loop(200000, 0)", 200000},
+		{"let loop = fn(n) { if (n == 0) { return 0; } return loop(n - 1); }; loop(200000)", 0},
+		{"let loop = fn(n) { if (n > 0) { return loop(n - 1); } n }; loop(200000)", 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+even(200001)`, false},
+		{"let f = fn(n) { len([n]) }; f(1)", 1},
+		{"let f = fn(a) { a }; let g = fn() { f() }; g()", "wrong number of arguments. got=0, want=1"},
+		{"let f = fn() { throw 1 }; let g = fn() { try { return f() } catch (e) { 2 } }; g()", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x: int = 5; x", 5},
+		{`let x: int = "5"; x`, "type error: x expects int, got STRING"},
+		{"let xs: array<int> = [1, 2]; len(xs)", 2},
+		{`let xs: array<int> = [1, "2"]; xs`, "type error: xs expects array<int>, got ARRAY containing STRING"},
+		{"let xs: array<array<int>> = [[1], [true]]; xs", "type error: xs expects array<array<int>>, got ARRAY containing ARRAY containing BOOLEAN"},
+		{`let h: hash<string, int> = {"a": 1}; h["a"]`, 1},
+		{`let h: hash<string, int> = {1: 1}; h`, "type error: h expects hash<string, int>, got HASH with key INTEGER"},
+		{`let h: hash<string, int> = {"a": "1"}; h`, "type error: h expects hash<string, int>, got HASH with value STRING"},
+		{"let f: fn = len; f([1])", 1},
+		{"let a: any = [true]; len(a)", 1},
+		{`let f = fn(a: string, b: array) -> bool { len(a) == len(b) }; f("ab", [1, 2])`, true},
+		{`let f = fn(a: string, b: array) -> bool { true }; f(1, [])`, "type error: parameter a of f expects string, got INTEGER"},
+		{`let f = fn(a: string, b: array) -> bool { true }; f("a", "b")`, "type error: parameter b of f expects array, got STRING"},
+		{"fn(n: int) { n }(true)", "type error: parameter n of <anonymous> expects int, got BOOLEAN"},
+		{"let f = fn(n) -> bool { n }; f(1)", "type error: f returns bool, got INTEGER"},
+		{"let f = fn(n) -> bool { return n > 0; 1 }; f(1)", true},
+		{"let f = fn() -> null { if (false) { 1 } }; f()", nil},
+		{"let f = fn() -> int { if (false) { 1 } }; f()", "type error: f returns int, got NULL"},
+		{"let f = fn(n: int) -> int { if (n == 0) { 0 } else { f(n - 1) } }; f(200000)", 0},
+		{`let g = fn() { "s" }; let f = fn() -> int { g() }; f()`, "type error: f returns int, got STRING"},
+		{`let g = fn(s: string) { s }; let f = fn() { g(1) }; f()`, "type error: parameter s of g expects string, got INTEGER"},
+		{`let f = fn(n: int) { n }; try { f("x") } catch (e) { e["message"] }`, "type error: parameter n of f expects int, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("wrong string for %q. expected=%q, got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTypeErrorPosition(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{`let x: int = "a"`, 1, 5},
+		{"let f = fn(n: int) { n };\nf(true)", 2, 2},
+		{"let f = fn() -> int { true };\nf()", 1, 17},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Line != tt.expectedLine || errObj.Column != tt.expectedColumn {
+			t.Errorf("wrong position for %q. expected=%d:%d, got=%d:%d",
+				tt.input, tt.expectedLine, tt.expectedColumn, errObj.Line, errObj.Column)
+		}
+	}
+}
+
+func TestTailCallReplacesFrame(t *testing.T) {
+	input := `let inner = fn() { 1 + true };
+let outer = fn() { inner() };
+outer() + 1`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	expected := object.Frame{Function: "inner", Line: 2, Column: 25}
+	if len(errObj.Stack) != 1 || errObj.Stack[0] != expected {
+		t.Errorf("wrong stack. expected=[%+v], got=%+v", expected, errObj.Stack)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	loop := "let loop = fn() { loop() }; loop()"
+	deep := "let deep = fn() { 1 + deep() }; "
+
+	tests := []struct {
+		ctx      context.Context
+		maxSteps int
+		maxDepth int
+		input    string
+		kind     object.ErrorKind
+		message  string
+	}{
+		{context.Background(), 1000, 0, loop, object.StepLimit, "step limit of 1000 exceeded"},
+		{context.Background(), 0, 50, deep + "deep()", object.DepthLimit, "call depth limit of 50 exceeded"},
+		{context.Background(), 0, 50, deep + "try { deep() } catch (e) { 0 }", object.DepthLimit, "call depth limit of 50 exceeded"},
+		{context.Background(), 0, 50, deep + "let f = fn() { try { deep() } finally { return 1 } }; f()", object.DepthLimit, "call depth limit of 50 exceeded"},
+		{canceled, 0, 0, "1", object.Canceled, "evaluation canceled: context canceled"},
+		{timeout, 0, 0, loop, object.Canceled, "evaluation canceled: context deadline exceeded"},
+		{context.Background(), 1000, 50, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)", object.RuntimeError, ""},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		e.MaxSteps = tt.maxSteps
+		e.MaxDepth = tt.maxDepth
+
+		evaluated := e.EvalContext(tt.ctx, program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if tt.kind == object.RuntimeError {
+			if ok {
+				t.Errorf("%q returned error %q", tt.input, errObj.Message)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.kind || !errObj.Aborted() {
+			t.Errorf("wrong error kind for %q. expected=%s, got=%s", tt.input, tt.kind, errObj.Kind)
+		}
+		if errObj.Message != tt.message {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.message, errObj.Message)
+		}
+	}
+}
+
+func TestLimitsResetBetweenRuns(t *testing.T) {
+	e := New()
+	e.MaxSteps = 100
+	env := object.NewEnvironment()
+
+	for i := 0; i < 3; i++ {
+		program := parser.New(lexer.New("let x = 1 + 2 * 3; x")).ParseProgram()
+		testIntegerObject(t, e.Eval(program, env), 7)
+	}
+
+	program := parser.New(lexer.New("let loop = fn() { loop() }; loop()")).ParseProgram()
+	if errObj, ok := e.Eval(program, env).(*object.Error); !ok || errObj.Kind != object.StepLimit {
+		t.Fatalf("step limit not hit")
+	}
+
+	program = parser.New(lexer.New("x")).ParseProgram()
+	testIntegerObject(t, e.Eval(program, env), 7)
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let grow = fn(s) { grow(s + s) }; grow("x")`, "memory limit of 100000 bytes exceeded"},
+		{`let fill = fn(a) { fill(push(a, 1)) }; fill([])`, "memory limit of 100000 bytes exceeded"},
+		{`let keys = fn(n) { {n: n}; keys(n + 1) }; keys(0)`, "memory limit of 100000 bytes exceeded"},
+		{`let nest = fn(a, n) { if (n == 0) { a } else { nest([a], n - 1) } }; json_stringify(nest(1, 150), 10)`,
+			"memory limit of 100000 bytes exceeded"},
+		{`let grow = fn(s) { grow(s + s) }; try { grow("x") } catch (e) { e["line"] }`, 1},
+		// pushing shares the backing array, so a long array stays within the limit
+		{`let fill = fn(a, n) { if (n == 0) { len(a) } else { fill(push(a, n), n - 1) } }; fill([], 500)`, 500},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		e.MaxMemory = 100000
+
+		evaluated := e.Eval(program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected || errObj.Aborted() {
+				t.Errorf("wrong error. expected=%q, got=%q (%s)", expected, errObj.Message, errObj.Kind)
+			}
+		}
+
+		if e.Allocated() > e.MaxMemory {
+			t.Errorf("allocated more than the limit for %q. got=%d", tt.input, e.Allocated())
+		}
+	}
+}
+
+func TestCallStackUnwinds(t *testing.T) {
+	input := `let f = fn() { 1 };
+f(); f();
+let g = fn() { try { f()(); } catch (e) { 0 }; 1 + true };
+g();`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if len(errObj.Stack) != 1 || errObj.Stack[0].Function != "g" {
+		t.Errorf("wrong stack. got=%+v", errObj.Stack)
+	}
+}
+
+func TestImportExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import "lib/math.mk"; m.square(4)`, 16},
+		{`let m = import "lib/math.mk"; m["double"](4)`, 8},
+		{`import "lib/math.mk" == import "lib/math.mk"`, true},
+		{`(import "main.mk") == (import "main.mk")`, true},
+		{`import "twice.mk"`, "module(twice.mk)"},
+		{`import "lib/math.mk".cube`, "module lib/math.mk has no binding cube"},
+		{`import "lib/nope.mk"`, "module not found: lib/nope.mk"},
+		{`import "lib/cycle_a.mk"`, "import cycle: cycle_a.mk -> cycle_b.mk -> cycle_a.mk"},
+		{`let h = {"a": 1}; h.a`, 1},
+		{`5.a`, "member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		e := New()
+		e.File = "testdata/main.mk"
+		e.SearchPath = []string{"testdata/lib"}
+		evaluated := e.Eval(program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil {
+				t.Errorf("no object returned for %q", tt.input)
+				continue
+			}
+			got := evaluated.Inspect()
+			if errObj, ok := evaluated.(*object.Error); ok {
+				got = errObj.Message
+			}
+			if got != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+// recorder a Debugger recording the line and call depth of each statement,
+// failing with err at line stopAt
+type recorder struct {
+	e      *Evaluator
+	events []string
+	stopAt int
+	err    error
+}
+
+func (r *recorder) Statement(stmt ast.Statement, env *object.Environment) error {
+	line := 0
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		line = stmt.Token.Line
+	case *ast.ExpressionStatement:
+		line = stmt.Token.Line
+	case *ast.ReturnStatement:
+		line = stmt.Token.Line
+	}
+	r.events = append(r.events, fmt.Sprintf("%d@%d", line, len(r.e.Stack())))
+	if line == r.stopAt {
+		return r.err
+	}
+	return nil
+}
+
+func TestImportErrorTraceback(t *testing.T) {
+	bad, err := filepath.Abs("testdata/lib/bad.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := filepath.Abs("testdata/lib/broken.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = import "lib/bad.mk";
+m.f(1)`, "Traceback (most recent call last):\n" +
+			"  testdata/main.mk:2:4, in <main>\n" +
+			"  " + bad + ":2:7, in f\n" +
+			"ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`import "lib/broken.mk"`, "Traceback (most recent call last):\n" +
+			"  " + broken + ":2:2, in <main>\n" +
+			"ERROR: wrong number of arguments. got=2, want=0"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		e := New()
+		e.File = "testdata/main.mk"
+		e.SearchPath = []string{"testdata/lib"}
+		evaluated := e.Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Traceback() != tt.expected {
+			t.Errorf("wrong traceback for %q. expected=%q, got=%q", tt.input, tt.expected, errObj.Traceback())
+		}
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	input := `let f = fn(n) {
+  let m = n + 1;
+  if (n < 2) { m } else { f(n - 1) }
+};
+let g = fn() { return f(2) + 1 };
+g();
+puts(g())`
+
+	tests := []struct {
+		stopAt   int
+		expected string
+		message  string
+	}{
+		// statements of blocks are told too, and f(n - 1) is a tail call,
+		// replacing the frame of f
+		{0, "1@0 5@0 6@0 5@1 2@2 3@2 3@2 2@2 3@2 3@2 7@0 5@1 2@2 3@2 3@2 2@2 3@2 3@2", ""},
+		{2, "1@0 5@0 6@0 5@1 2@2", "evaluation canceled: quit"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := New()
+		e.Out = &out
+		r := &recorder{e: e, stopAt: tt.stopAt, err: errors.New("quit")}
+		e.Debugger = r
+
+		evaluated := e.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+
+		if got := strings.Join(r.events, " "); got != tt.expected {
+			t.Errorf("statements wrong.\nexpected=%q\ngot=%q", tt.expected, got)
+		}
+		if tt.message == "" {
+			if out.String() != "3\n" {
+				t.Errorf("puts output wrong. got=%q", out.String())
+			}
+			continue
+		}
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || errObj.Kind != object.Canceled || errObj.Message != tt.message {
+			t.Errorf("wrong result. expected %q, got=%+v", tt.message, evaluated)
+		}
+	}
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -514,8 +1158,8 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 		return false
 	}
 
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	if result.Value() != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value(), expected)
 		return false
 	}
 
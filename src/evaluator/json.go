@@ -0,0 +1,195 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"object"
+	"strings"
+)
+
+// JSON values map to objects as follows, in both directions:
+//
+//	object          HASH, with STRING keys
+//	array           ARRAY
+//	integral number INTEGER
+//	other number    FLOAT
+//	string          STRING
+//	true, false     BOOLEAN
+//	null            NULL
+//
+// json_stringify also accepts INTEGER, FLOAT and BOOLEAN hash keys, written as
+// strings, as long as no two keys of a hash are written the same. Hashes keep
+// no insertion order, their pairs being stored by hash key, so object members
+// are written sorted by key for the same hash to always give the same text.
+// Both charge what they allocate to m.
+
+// maxJSONIndent largest number of spaces or tabs json_stringify indents a
+// level by
+const maxJSONIndent = 10
+
+func (m *memory) jsonParse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `json_parse` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return newError("json_parse: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("json_parse: unexpected data after top-level value")
+	}
+
+	result := jsonToObject(value)
+	if err := m.chargeObject(result); err != nil {
+		return err
+	}
+	return result
+}
+
+func jsonToObject(value interface{}) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case string:
+		return &object.String{Value: value}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return object.NewInteger(i)
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return newError("json_parse: number %s out of range", value)
+		}
+		return &object.Float{Value: f}
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, el := range value {
+			elements[i] = jsonToObject(el)
+			if isError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for k, v := range value {
+			key := &object.String{Value: k}
+			val := jsonToObject(v)
+			if isError(val) {
+				return val
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}
+	}
+
+	return newError("json_parse: unexpected value %v", value)
+}
+
+func (m *memory) jsonStringify(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value() < 0 || arg.Value() > maxJSONIndent {
+				return newError("indent for `json_stringify` must be between 0 and %d, got %d", maxJSONIndent, arg.Value())
+			}
+			indent = strings.Repeat(" ", int(arg.Value()))
+		case *object.String:
+			if len(arg.Value) > maxJSONIndent || strings.Trim(arg.Value, " \t") != "" {
+				return newError("indent for `json_stringify` must be at most %d spaces or tabs, got %q", maxJSONIndent, arg.Value)
+			}
+			indent = arg.Value
+		default:
+			return newError("indent for `json_stringify` must be INTEGER or STRING, got %s", args[1].Type())
+		}
+	}
+
+	value, err := objectToJSON(args[0])
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(value); err != nil {
+		return newError("json_stringify: %s", err)
+	}
+
+	str := strings.TrimSuffix(out.String(), "\n")
+	if err := m.chargeString(len(str)); err != nil {
+		return err
+	}
+	return &object.String{Value: str}
+}
+
+// objectToJSON convert obj into a value encoding/json writes as described
+// above. Maps are written sorted by key
+func objectToJSON(obj object.Object) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value(), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := objectToJSON(el)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *object.Hash:
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			var key string
+			switch k := pair.Key.(type) {
+			case *object.String:
+				key = k.Value
+			case *object.Integer, *object.Float, *object.Boolean:
+				key = k.Inspect()
+			}
+			if _, ok := values[key]; ok {
+				return nil, newError("json_stringify: duplicate key %q", key)
+			}
+			value, err := objectToJSON(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+
+	return nil, newError("json_stringify: unsupported value %s", obj.Type())
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"context"
+	"fmt"
+	"object"
+	"os"
+)
+
+// DefaultMaxDepth call depth the monkey command and Interpreter stop at, deep
+// enough for recursive programs yet far from overflowing the Go stack, which
+// would crash the process rather than raise an error
+const DefaultMaxDepth = 10000
+
+// cancelCheckInterval number of steps between two looks at the context
+const cancelCheckInterval = 256
+
+// EvalContext evaluate node like Eval, stopping when ctx is done or a budget
+// of the Evaluator runs out. The error returned then has a Kind other than
+// object.RuntimeError, and try expressions do not catch it. Budgets, and the
+// memory counted against MaxMemory, count from the start of each EvalContext
+// or ApplyContext but a nested one: made while another is running, by a Go
+// function the script called, it shares the budgets of the running one
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if err := e.start(ctx); err != nil {
+		e.stop()
+		return err
+	}
+	defer e.stop()
+
+	return e.Eval(node, env)
+}
+
+// ApplyContext call fn like Apply, within the limits EvalContext enforces
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	if err := e.start(ctx); err != nil {
+		e.stop()
+		return err
+	}
+	defer e.stop()
+
+	return e.Apply(fn, args...)
+}
+
+// running report whether an evaluation is in progress
+func (e *Evaluator) running() bool {
+	return len(e.ctxs) != 0
+}
+
+func (e *Evaluator) start(ctx context.Context) *object.Error {
+	if !e.running() {
+		e.steps = 0
+		e.aborted = nil
+		e.mem = memory{limit: e.MaxMemory}
+		if e.builtins == nil {
+			out := e.Out
+			if out == nil {
+				out = os.Stdout
+			}
+			e.builtins = newBuiltins(&e.mem, out)
+		}
+	}
+	e.ctxs = append(e.ctxs, ctx)
+
+	if err := ctx.Err(); err != nil {
+		return e.abort(object.Canceled, "evaluation canceled: %s", err)
+	}
+	return nil
+}
+
+func (e *Evaluator) stop() {
+	e.ctxs = e.ctxs[:len(e.ctxs)-1]
+	if !e.running() {
+		e.aborted = nil
+		return
+	}
+
+	// a nested evaluation canceled by its own context leaves the one that
+	// made it running. A limit hit stops both, the budgets being shared, and
+	// a context of the outer one done is seen again at its next check
+	if e.aborted != nil && e.aborted.Kind == object.Canceled {
+		e.aborted = nil
+	}
+}
+
+// step count an evaluation step, returning an error once evaluation must stop
+func (e *Evaluator) step() *object.Error {
+	if e.aborted != nil {
+		return e.aborted
+	}
+
+	e.steps++
+	if e.MaxSteps > 0 && e.steps > e.MaxSteps {
+		return e.abort(object.StepLimit, "step limit of %d exceeded", e.MaxSteps)
+	}
+
+	if e.steps%cancelCheckInterval == 0 {
+		for _, ctx := range e.ctxs {
+			select {
+			case <-ctx.Done():
+				return e.abort(object.Canceled, "evaluation canceled: %s", ctx.Err())
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// abort stop the evaluation with an error of kind. The error is returned by
+// every step from then on, so that nothing runs until the evaluation unwinds
+func (e *Evaluator) abort(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	e.aborted = &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+	return e.aborted
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import "object"
+
+// approximate sizes in bytes of what strings, arrays and hashes allocate
+const (
+	stringSize = 32 // object and string header
+	arraySize  = 48 // object and slice header
+	hashSize   = 64 // object and map header
+	slotSize   = 16 // an array element, an interface value
+	pairSize   = 80 // a hash entry, key and pair
+)
+
+// memory accountant of the bytes allocated for strings, arrays and hashes.
+// The methods of a nil *memory charge nothing
+type memory struct {
+	limit     int64
+	allocated int64
+}
+
+// charge account for n bytes about to be allocated, returning a catchable
+// error instead if that would exceed the limit
+func (m *memory) charge(n int64) *object.Error {
+	if m == nil {
+		return nil
+	}
+
+	if m.limit > 0 && m.allocated+n > m.limit {
+		return newError("memory limit of %d bytes exceeded", m.limit)
+	}
+	m.allocated += n
+	return nil
+}
+
+func (m *memory) chargeString(length int) *object.Error {
+	return m.charge(stringSize + int64(length))
+}
+
+func (m *memory) chargeArray(length int) *object.Error {
+	return m.charge(arraySize + slotSize*int64(length))
+}
+
+func (m *memory) chargeHash(length int) *object.Error {
+	return m.charge(hashSize + pairSize*int64(length))
+}
+
+// chargeObject account for obj and everything in it, all newly allocated
+func (m *memory) chargeObject(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.String:
+		return m.chargeString(len(obj.Value))
+	case *object.Array:
+		for _, el := range obj.Elements {
+			if err := m.chargeObject(el); err != nil {
+				return err
+			}
+		}
+		return m.chargeArray(cap(obj.Elements))
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			if err := m.chargeObject(pair.Key); err != nil {
+				return err
+			}
+			if err := m.chargeObject(pair.Value); err != nil {
+				return err
+			}
+		}
+		return m.chargeHash(len(obj.Pairs))
+	}
+	return nil
+}
+
+// chargeInfix account for the string a concatenation of left and right makes,
+// before it is made
+func (e *Evaluator) chargeInfix(operator string, left, right object.Object) *object.Error {
+	l, ok := left.(*object.String)
+	if !ok || operator != "+" {
+		return nil
+	}
+	r, ok := right.(*object.String)
+	if !ok {
+		return nil
+	}
+	return e.mem.chargeString(len(l.Value) + len(r.Value))
+}
+
+// Allocated approximate number of bytes allocated for strings, arrays and
+// hashes since the evaluation started
+func (e *Evaluator) Allocated() int64 {
+	return e.mem.allocated
+}
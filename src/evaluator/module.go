@@ -0,0 +1,118 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"io/ioutil"
+	"lexer"
+	"object"
+	"os"
+	"parser"
+	"path/filepath"
+	"strings"
+)
+
+func (e *Evaluator) evalImportExpression(ie *ast.ImportExpression) object.Object {
+	path, ok := e.resolveImport(ie.Path)
+	if !ok {
+		return newError("module not found: %s", ie.Path)
+	}
+
+	if module, ok := e.modules[path]; ok {
+		return module
+	}
+
+	for i, loading := range e.loading {
+		if loading == path {
+			var cycle []string
+			for _, p := range append(e.loading[i:len(e.loading):len(e.loading)], path) {
+				cycle = append(cycle, filepath.Base(p))
+			}
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newError("could not read module %s: %s", ie.Path, err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("could not parse module %s: %s", ie.Path, p.Errors()[0])
+	}
+
+	file := e.File
+	e.File = path
+	e.loading = append(e.loading, path)
+	defer func() {
+		e.File = file
+		e.loading = e.loading[:len(e.loading)-1]
+	}()
+
+	env := object.NewEnvironment()
+	if result := e.Eval(program, env); isError(result) {
+		return result
+	}
+
+	module := &object.Module{Name: ie.Path, Path: path, Env: env}
+	if e.modules == nil {
+		e.modules = make(map[string]*object.Module)
+	}
+	e.modules[path] = module
+
+	return module
+}
+
+// resolveImport find the module file for name, first relative to the file
+// being evaluated (or the working directory), then in each SearchPath entry
+func (e *Evaluator) resolveImport(name string) (string, bool) {
+	var candidates []string
+
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, name)
+	} else {
+		dir := "."
+		if e.File != "" {
+			dir = filepath.Dir(e.File)
+		}
+		candidates = append(candidates, filepath.Join(dir, name))
+
+		for _, dir := range e.SearchPath {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if abs, err := filepath.Abs(candidate); err == nil {
+			return abs, true
+		}
+		return candidate, true
+	}
+
+	return "", false
+}
+
+func evalModuleMember(module *object.Module, name string) object.Object {
+	if val, ok := module.Env.Get(name); ok {
+		return val
+	}
+
+	return newError("module %s has no binding %s", module.Name, name)
+}
+
+// Reset forget the modules imported so far, so that the next import of each
+// loads it again. The configuration of the Evaluator is kept
+func (e *Evaluator) Reset() {
+	e.modules = nil
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"object"
+	"strconv"
+	"token"
+)
+
+// Optimize rewrite program in place into one that evaluates to the same
+// values and errors, at the same positions, in fewer steps:
+//
+//   - prefix and infix expressions of integer, boolean and string literals
+//     are folded into a literal, unless evaluating them raises an error;
+//   - the branch of an if expression with a literal condition that is not
+//     taken is removed, and an if statement with a literal condition is
+//     replaced by the statements of the branch taken;
+//   - a name bound by let to an integer or boolean literal, and bound nowhere
+//     else in its function, is replaced by the literal in the statements
+//     following the let in its block. Globals are not replaced inside
+//     functions, which may run after another program bound them again.
+//
+// Let statements are kept, so that modules export the same names. Strings
+// are not inlined since each evaluation of a string literal makes a distinct
+// object for ==. Optimize is meant for resolved programs: a name that is not
+// bound anywhere in a removed branch would no longer be reported
+func Optimize(program *ast.Program) *ast.Program {
+	o := &optimizer{}
+	o.push(true, nil, program)
+	program.Statements = o.statements(program.Statements)
+	return program
+}
+
+type optFrame struct {
+	global bool
+	params map[string]bool
+	// lets counts the let statements binding each name in the frame
+	lets map[string]int
+}
+
+type optimizer struct {
+	frames []*optFrame
+}
+
+func (o *optimizer) push(global bool, params []*ast.Identifier, body ast.Node) {
+	f := &optFrame{global: global, params: map[string]bool{}, lets: map[string]int{}}
+	for _, param := range params {
+		f.params[param.Value] = true
+	}
+	countLets(body, f.lets)
+	o.frames = append(o.frames, f)
+}
+
+func (o *optimizer) pop() {
+	o.frames = o.frames[:len(o.frames)-1]
+}
+
+func (o *optimizer) frame() *optFrame {
+	return o.frames[len(o.frames)-1]
+}
+
+// countLets count the let statements binding each name directly in body, that
+// is not in nested functions or catch blocks, which have frames of their own
+func countLets(body ast.Node, counts map[string]int) {
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		case *ast.LetStatement:
+			counts[node.Name.Value]++
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+}
+
+// binds report whether a frame with params and body binds name
+func binds(params []*ast.Identifier, body ast.Node, name string) bool {
+	for _, param := range params {
+		if param.Value == name {
+			return true
+		}
+	}
+	counts := map[string]int{}
+	countLets(body, counts)
+	return counts[name] > 0
+}
+
+func (o *optimizer) statements(stmts []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(stmts))
+
+	for i := range stmts {
+		stmt := o.statement(stmts[i])
+
+		if let, ok := stmt.(*ast.LetStatement); ok && o.inlinable(let) {
+			inline(stmts[i+1:], let.Name.Value, let.Value, o.frame().global)
+		}
+
+		// optimized ifs with a literal condition have no alternative
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok && isLiteral(ie.Condition) {
+				// the value of the last statement is that of its block, so an
+				// empty one must stay to evaluate to null
+				last := i == len(stmts)-1
+				if !last || len(ie.Consequence.Statements) > 0 {
+					if isTruthy(literalObject(ie.Condition)) {
+						out = append(out, ie.Consequence.Statements...)
+					}
+					continue
+				}
+			}
+		}
+
+		out = append(out, stmt)
+	}
+
+	return out
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = o.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.ThrowStatement:
+		stmt.Value = o.expression(stmt.Value)
+	}
+	return stmt
+}
+
+func (o *optimizer) block(block *ast.BlockStatement) *ast.BlockStatement {
+	if block != nil {
+		block.Statements = o.statements(block.Statements)
+	}
+	return block
+}
+
+func (o *optimizer) expressions(exprs []ast.Expression) {
+	for i, expr := range exprs {
+		exprs[i] = o.expression(expr)
+	}
+}
+
+func (o *optimizer) expression(expr ast.Expression) ast.Expression {
+	switch node := expr.(type) {
+	case *ast.PrefixExpression:
+		node.Right = o.expression(node.Right)
+		if isLiteral(node.Right) {
+			return fold(node.Token, node, evalPrefixExpression(node.Operator, literalObject(node.Right)))
+		}
+	case *ast.InfixExpression:
+		node.Left = o.expression(node.Left)
+		node.Right = o.expression(node.Right)
+		if isLiteral(node.Left) && isLiteral(node.Right) {
+			return fold(node.Token, node, evalInfixExpression(node.Operator, literalObject(node.Left), literalObject(node.Right)))
+		}
+	case *ast.IfExpression:
+		node.Condition = o.expression(node.Condition)
+		node.Consequence = o.block(node.Consequence)
+		node.Alternative = o.block(node.Alternative)
+		if isLiteral(node.Condition) {
+			return prune(node)
+		}
+	case *ast.FunctionLiteral:
+		o.push(false, node.Parameters, node.Body)
+		node.Body = o.block(node.Body)
+		o.pop()
+	case *ast.CallExpression:
+		node.Function = o.expression(node.Function)
+		o.expressions(node.Arguments)
+	case *ast.ArrayLiteral:
+		o.expressions(node.Elements)
+	case *ast.IndexExpression:
+		node.Left = o.expression(node.Left)
+		node.Index = o.expression(node.Index)
+	case *ast.HashLiteral:
+		keys := node.OrderedKeys()
+		pairs := make(map[ast.Expression]ast.Expression, len(keys))
+		for i, key := range keys {
+			value := node.Pairs[key]
+			keys[i] = o.expression(key)
+			pairs[keys[i]] = o.expression(value)
+		}
+		node.Keys, node.Pairs = keys, pairs
+	case *ast.TryExpression:
+		node.Block = o.block(node.Block)
+		if node.Catch != nil {
+			o.push(false, []*ast.Identifier{node.Param}, node.Catch)
+			node.Catch = o.block(node.Catch)
+			o.pop()
+		}
+		node.Finally = o.block(node.Finally)
+	case *ast.MemberExpression:
+		node.Object = o.expression(node.Object)
+	}
+	return expr
+}
+
+// inlinable report whether uses of the name let binds may be replaced by its
+// value
+func (o *optimizer) inlinable(let *ast.LetStatement) bool {
+	switch let.Value.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean:
+	default:
+		return false
+	}
+
+	f := o.frame()
+	return f.lets[let.Name.Value] == 1 && !f.params[let.Name.Value]
+}
+
+// inline replace the uses of name in stmts that refer to the binding of the
+// current frame by copies of value. Functions are skipped if global is set
+func inline(stmts []ast.Statement, name string, value ast.Expression, global bool) {
+	uses := map[*ast.Identifier]bool{}
+
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if node.Value == name {
+				uses[node] = true
+			}
+		case *ast.LetStatement:
+			if node.Value != nil {
+				ast.Inspect(node.Value, visit)
+			}
+			return false
+		case *ast.MemberExpression:
+			ast.Inspect(node.Object, visit)
+			return false
+		case *ast.FunctionLiteral:
+			return !global && !binds(node.Parameters, node.Body, name)
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Catch != nil && !binds([]*ast.Identifier{node.Param}, node.Catch, name) {
+				ast.Inspect(node.Catch, visit)
+			}
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, visit)
+	}
+
+	if len(uses) == 0 {
+		return
+	}
+
+	for i, stmt := range stmts {
+		stmts[i] = ast.Modify(stmt, func(node ast.Node) ast.Node {
+			ident, ok := node.(*ast.Identifier)
+			if !ok || !uses[ident] {
+				return node
+			}
+			return objectLiteral(ident.Token, literalObject(value))
+		}).(ast.Statement)
+	}
+}
+
+// prune drop the branch of ie that is not taken, ie having a literal
+// condition
+func prune(ie *ast.IfExpression) *ast.IfExpression {
+	if isTruthy(literalObject(ie.Condition)) {
+		ie.Alternative = nil
+		return ie
+	}
+
+	if ie.Alternative != nil {
+		return &ast.IfExpression{
+			Token:       ie.Token,
+			Condition:   &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Line: ie.Token.Line, Column: ie.Token.Column}, Value: true},
+			Consequence: ie.Alternative,
+		}
+	}
+
+	ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token, Rbrace: ie.Consequence.Rbrace}
+	return ie
+}
+
+// fold replace node by a literal of the value it evaluated to, at the
+// position of tok, unless it raised an error
+func fold(tok token.Token, node ast.Expression, value object.Object) ast.Expression {
+	if lit := objectLiteral(tok, value); lit != nil {
+		return lit
+	}
+	return node
+}
+
+func isLiteral(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral:
+		return true
+	}
+	return false
+}
+
+// literalObject the object a literal evaluates to
+func literalObject(expr ast.Expression) object.Object {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(expr.Value)
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(expr.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: expr.Value}
+	}
+	return nil
+}
+
+// objectLiteral a literal at the position of tok evaluating to obj, nil if
+// there is none
+func objectLiteral(tok token.Token, obj object.Object) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, strconv.FormatInt(obj.Value(), 10)
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value()}
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if obj.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}
+	case *object.String:
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"lexer"
+	"object"
+	"parser"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{"-(2 - 5)", "3"},
+		{"!(1 < 2)", "false"},
+		{`"a" + "b"`, "ab"},
+		{"x * (2 + 3)", "(x * 5)"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"if (true) { a } else { b }", "a"},
+		{"if (1 > 2) { a } else { b }", "b"},
+		{"if (1 > 2) { a }; b", "b"},
+		{"f(if (false) { a } else { b })", "f(iftrue { b })"},
+		{"f(if (false) { a })", "f(iffalse {  })"},
+		{"let x = 2; x * 3", "let x = 2;6"},
+		{"let f = fn(n) { let k = 10; n * k };", "let f = fn(n) { let k = 10;(n * 10) };"},
+		{"let x = 1; let f = fn() { x };", "let x = 1;let f = fn() { x };"},
+		{"let x = 1; let x = 2; x", "let x = 1;let x = 2;x"},
+		{"let f = fn(x) { let x = 1; x };", "let f = fn(x) { let x = 1;x };"},
+		{`let s = "a"; s == s`, "let s = a;(s == s)"},
+		{"fn() { let x = 1; fn(x) { x } }", "fn() { let x = 1;fn(x) { x } }"},
+		{"fn() { f(x); let x = 1; x }", "fn() { f(x)let x = 1;1 }"},
+		{"fn() { if (c) { let x = 1; }; x }", "fn() { ifc { let x = 1; }x }"},
+		{"fn() { let x = 1; try { x } catch (x) { x } }", "fn() { let x = 1;try { 1 } catch (x) { x } }"},
+		{"let m = 3; m.size", "let m = 3;(3.size)"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		if got := Optimize(program).String(); got != tt.expected {
+			t.Errorf("Optimize(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOptimizePreservesSemantics(t *testing.T) {
+	inputs := []string{
+		"1 / 0",
+		"let f = fn(n) { n + 1 / 0 }; f(1)",
+		"let big = 60 * 60 * 24; let f = fn(days) { days * big }; f(7)",
+		`let s = "a"; s == s`,
+		`"a" == "a"`,
+		"let x = 1; x()",
+		"if (true) { let y = 1; }; y",
+		"if (false) { let y = 1; }; y",
+		"let f = fn() { 5; if (false) { 1 } }; f()",
+		"let f = fn(n) { let flag = true; if (flag) { return n * 2 } 0 }; f(21)",
+		"let f = fn(n) { let n = 5; n }; f(1)",
+		"-true",
+		"try { 10 / (5 - 5) } catch (e) { e[\"column\"] }",
+	}
+
+	for _, input := range inputs {
+		plain := New().Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+
+		e := New()
+		e.Optimize = true
+		optimized := e.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+
+		if plain == nil || optimized == nil {
+			if plain != optimized {
+				t.Errorf("%q evaluated differently. plain=%v, optimized=%v", input, plain, optimized)
+			}
+			continue
+		}
+		if plain.Inspect() != optimized.Inspect() {
+			t.Errorf("%q evaluated differently. plain=%q, optimized=%q", input, plain.Inspect(), optimized.Inspect())
+		}
+
+		plainErr, _ := plain.(*object.Error)
+		optimizedErr, _ := optimized.(*object.Error)
+		if plainErr != nil && optimizedErr != nil && (plainErr.Line != optimizedErr.Line || plainErr.Column != optimizedErr.Column) {
+			t.Errorf("%q raised errors at different positions. plain=%d:%d, optimized=%d:%d",
+				input, plainErr.Line, plainErr.Column, optimizedErr.Line, optimizedErr.Column)
+		}
+	}
+}
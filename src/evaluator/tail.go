@@ -0,0 +1,113 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"object"
+	"token"
+)
+
+// tailCallObj the type of a pending tail call, never seen by monkey code
+const tailCallObj = "TAIL_CALL"
+
+// tailCall a call in tail position of a function body, left for applyFunction
+// to make in place of the function that returned it, so that the Go stack
+// does not grow with tail recursion
+type tailCall struct {
+	call token.Token
+	fn   *object.Function
+	args []object.Object
+}
+
+// Type implement Object interface
+func (tc *tailCall) Type() object.Type { return tailCallObj }
+
+// Inspect implement Object interface
+func (tc *tailCall) Inspect() string { return "tail call of " + tc.fn.Inspect() }
+
+// evalTailStatements evaluate the statements of block, a function body or a
+// block nested in it through if expressions only. Return values are always in
+// tail position, the value of the last statement only when last is true
+func (e *Evaluator) evalTailStatements(block *ast.BlockStatement, env *object.Environment, last bool) object.Object {
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		tail := last && i == len(block.Statements)-1
+		if err := e.debug(statement, env); err != nil {
+			return err
+		}
+
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			val := e.evalTailExpression(statement.ReturnValue, env)
+			if isError(val) {
+				return val
+			}
+			return &object.ReturnValue{Value: val}
+		case *ast.ExpressionStatement:
+			if ie, ok := statement.Expression.(*ast.IfExpression); ok {
+				result = e.evalTailIf(ie, env, tail)
+			} else if tail {
+				result = e.evalTailExpression(statement.Expression, env)
+			} else {
+				result = e.eval(statement, env)
+			}
+		default:
+			result = e.eval(statement, env)
+		}
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURNVALUEOBJ || rt == object.ERROROBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// evalTailIf evaluate an if expression whose branches are in tail position
+// when last is true
+func (e *Evaluator) evalTailIf(ie *ast.IfExpression, env *object.Environment, last bool) object.Object {
+	condition := e.eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return e.evalTailStatements(ie.Consequence, env, last)
+	} else if ie.Alternative != nil {
+		return e.evalTailStatements(ie.Alternative, env, last)
+	}
+	return NULL
+}
+
+// evalTailExpression evaluate an expression in tail position. A call of a
+// monkey function is not made but returned as a tailCall
+func (e *Evaluator) evalTailExpression(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.IfExpression:
+		return e.evalTailIf(node, env, true)
+	case *ast.CallExpression:
+		function := e.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+
+		if fn, ok := function.(*object.Function); ok {
+			return &tailCall{call: node.Token, fn: fn, args: args}
+		}
+		return e.errorAt(node.Token, e.applyFunction(node.Token, function, args))
+	default:
+		return e.eval(node, env)
+	}
+}
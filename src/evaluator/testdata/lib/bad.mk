@@ -0,0 +1,3 @@
+let f = fn(x) {
+    x + true
+};
@@ -0,0 +1,2 @@
+let g = fn() { 1 };
+g(true, 2);
@@ -0,0 +1,2 @@
+let square = fn(x) { x * x };
+let double = fn(x) { x + x };
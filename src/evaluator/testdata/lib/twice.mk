@@ -0,0 +1,2 @@
+let math = import "math.mk";
+let four = math.double(2);
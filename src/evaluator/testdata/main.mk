@@ -0,0 +1,3 @@
+let math = import "lib/math.mk";
+let twice = import "lib/twice.mk";
+math.square(3) + twice.four
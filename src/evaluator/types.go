@@ -0,0 +1,127 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package evaluator
+
+import (
+	"ast"
+	"object"
+)
+
+// checkLet check val against the annotation of the let name, if any
+func checkLet(name *ast.Identifier, val object.Object) *object.Error {
+	if name.Type == nil {
+		return nil
+	}
+	if actual, ok := hasType(name.Type, val); !ok {
+		return newError("type error: %s expects %s, got %s", name.Value, name.Type, actual)
+	}
+	return nil
+}
+
+// checkArgs check args against the annotations of the parameters of fn
+func checkArgs(fn *object.Function, args []object.Object) *object.Error {
+	for i, param := range fn.Parameters {
+		if param.Type == nil {
+			continue
+		}
+		if actual, ok := hasType(param.Type, args[i]); !ok {
+			return newError("type error: parameter %s of %s expects %s, got %s",
+				param.Value, functionName(fn), param.Type, actual)
+		}
+	}
+	return nil
+}
+
+// returnChecks functions whose return types a call result must have, each
+// return type once so that tail recursion does not grow the list
+type returnChecks []*object.Function
+
+func (rc *returnChecks) add(fn *object.Function) {
+	if fn.ReturnType == nil {
+		return
+	}
+	for _, other := range *rc {
+		if other.ReturnType == fn.ReturnType {
+			return
+		}
+	}
+	*rc = append(*rc, fn)
+}
+
+// checkReturn check val against the return types of rc, reporting a mismatch
+// at the annotation
+func (e *Evaluator) checkReturn(rc returnChecks, val object.Object) object.Object {
+	for _, fn := range rc {
+		if actual, ok := hasType(fn.ReturnType, val); !ok {
+			err := newError("type error: %s returns %s, got %s", functionName(fn), fn.ReturnType, actual)
+			return e.errorAt(fn.ReturnType.Token, err)
+		}
+	}
+	return val
+}
+
+// hasType report whether obj has the type ta. If not, actual describes what
+// obj is instead, naming the element that does not fit in an array or hash
+func hasType(ta *ast.TypeAnnotation, obj object.Object) (actual string, ok bool) {
+	if obj == nil {
+		obj = NULL
+	}
+
+	switch ta.Name {
+	case "any":
+		return "", true
+	case "fn":
+		if obj.Type() == object.FUNCTIONOBJ || obj.Type() == object.BUILTINOBJ {
+			return "", true
+		}
+	case "array":
+		array, isArray := obj.(*object.Array)
+		if !isArray {
+			break
+		}
+		if len(ta.Params) == 0 {
+			return "", true
+		}
+		for _, el := range array.Elements {
+			if actual, ok := hasType(ta.Params[0], el); !ok {
+				return "ARRAY containing " + actual, false
+			}
+		}
+		return "", true
+	case "hash":
+		hash, isHash := obj.(*object.Hash)
+		if !isHash {
+			break
+		}
+		if len(ta.Params) == 0 {
+			return "", true
+		}
+		for _, pair := range hash.Pairs {
+			if actual, ok := hasType(ta.Params[0], pair.Key); !ok {
+				return "HASH with key " + actual, false
+			}
+			if actual, ok := hasType(ta.Params[1], pair.Value); !ok {
+				return "HASH with value " + actual, false
+			}
+		}
+		return "", true
+	default:
+		if obj.Type() == scalarTypes[ta.Name] {
+			return "", true
+		}
+	}
+
+	return string(obj.Type()), false
+}
+
+// scalarTypes object types of the type names without elements
+var scalarTypes = map[string]object.Type{
+	"int":    object.INTEGEROBJ,
+	"float":  object.FLOATOBJ,
+	"string": object.STRINGOBJ,
+	"bool":   object.BOOLEANOBJ,
+	"null":   object.NULLOBJ,
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"format"
+	"io"
+	"io/ioutil"
+)
+
+const fmtUsage = `usage: monkey fmt [-check | -w] [file ...]
+
+Format monkey source files in the canonical style and print the result. With
+no files, format standard input.
+
+flags:
+`
+
+// runFmt the fmt subcommand. With -check it lists the files that are not
+// formatted and fails if there are any, with -w it rewrites them in place
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, fmtUsage)
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "list files whose formatting differs and exit 1 if there are any")
+	write := flags.Bool("w", false, "write the result back to the files instead of printing it")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *check && *write {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 && *write {
+		fmt.Fprintln(stderr, "monkey fmt: cannot use -w with standard input")
+		return exitUsage
+	}
+
+	return eachSource("fmt", flags.Args(), stdin, stderr, func(path string, src []byte) int {
+		return formatSource(path, src, *check, *write, stdout, stderr)
+	})
+}
+
+func formatSource(path string, src []byte, check bool, write bool, stdout io.Writer, stderr io.Writer) int {
+	res, err := format.Source(src)
+	if err != nil {
+		if perr, ok := err.(*format.ParseError); ok {
+			for _, msg := range perr.Errors {
+				fmt.Fprintf(stderr, "%s: parse error: %s\n", path, msg)
+			}
+		} else {
+			fmt.Fprintf(stderr, "%s: %s\n", path, err)
+		}
+		return exitUsage
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(src, res) {
+			fmt.Fprintln(stdout, path)
+			return 1
+		}
+	case write:
+		if bytes.Equal(src, res) {
+			return 0
+		}
+		if err := ioutil.WriteFile(path, res, 0644); err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return exitUsage
+		}
+	default:
+		stdout.Write(res)
+	}
+
+	return 0
+}
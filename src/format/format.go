@@ -0,0 +1,526 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package format pretty-prints monkey programs in one canonical style: tab
+// indentation, single spaces around binary operators and after commas, one
+// statement per line and calls or literals broken one element per line when
+// they do not fit in MaxWidth columns or have comments among their elements.
+// Comments and single blank lines between statements are kept.
+package format
+
+import (
+	"ast"
+	"bytes"
+	"fmt"
+	"lexer"
+	"parser"
+	"strconv"
+	"strings"
+	"token"
+)
+
+// MaxWidth column limit past which calls and literals are broken up
+const MaxWidth = 80
+
+// tabWidth columns a tab counts for against MaxWidth
+const tabWidth = 4
+
+// ParseError the source could not be parsed, so it was not formatted
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Source format the monkey program src. A leading #! line is kept as is
+func Source(src []byte) ([]byte, error) {
+	text := string(src)
+
+	shebang := ""
+	if strings.HasPrefix(text, "#!") {
+		end := strings.IndexByte(text, '\n')
+		if end < 0 {
+			end = len(text)
+		}
+		// blank the line out so positions still match the source
+		shebang, text = text[:end], strings.Repeat(" ", end)+text[end:]
+	}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	pr := newPrinter()
+	pr.scan(text)
+	pr.program(program)
+
+	var out bytes.Buffer
+	if shebang != "" {
+		out.WriteString(shebang)
+		out.WriteString("\n")
+	}
+	out.Write(pr.buf.Bytes())
+	if pr.buf.Len() != 0 {
+		out.WriteString("\n")
+	}
+
+	return out.Bytes(), nil
+}
+
+// Node format node in the canonical style. There are no comments to keep,
+// as those only exist in the source
+func Node(node ast.Node) string {
+	pr := newPrinter()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.program(node)
+	case ast.Statement:
+		pr.statement(node, true)
+	case ast.Expression:
+		pr.expr(node, parser.LOWEST)
+	default:
+		panic(fmt.Sprintf("format: unexpected node %T", node))
+	}
+
+	return pr.buf.String()
+}
+
+// position of a token in the source
+type position struct {
+	line, column int
+}
+
+func (p position) before(o position) bool {
+	return p.line < o.line || p.line == o.line && p.column < o.column
+}
+
+func positionOf(tok token.Token) position {
+	return position{tok.Line, tok.Column}
+}
+
+// end is after every position in the source
+var end = position{int(^uint(0) >> 1), 0}
+
+type comment struct {
+	tok token.Token
+	// trailing is set when the comment follows code on the same line
+	trailing bool
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+
+	comments []comment
+	next     int // first comment not printed yet
+
+	// blank positions of the tokens and comments preceded by a blank line
+	blank map[position]bool
+	// opened is set right after an opening brace, where no blank line goes
+	opened bool
+	// flat is set when measuring, lists are then never broken
+	flat bool
+}
+
+func newPrinter() *printer {
+	return &printer{blank: map[position]bool{}}
+}
+
+// scan collect the comments of src and note where blank lines are
+func (p *printer) scan(src string) {
+	l := lexer.New(src)
+
+	var toks []token.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		toks = append(toks, tok)
+	}
+
+	// merge comments into the token stream, both are in source order
+	comments := l.Comments()
+	var all []token.Token
+	for i, j := 0, 0; i < len(toks) || j < len(comments); {
+		if j == len(comments) || i < len(toks) && positionOf(toks[i]).before(positionOf(comments[j])) {
+			all = append(all, toks[i])
+			i++
+		} else {
+			all = append(all, comments[j])
+			j++
+		}
+	}
+
+	for i, tok := range all {
+		if i == 0 {
+			continue
+		}
+		prev := all[i-1]
+		// a string may span lines, so measure from where it ends
+		prevLine := prev.Line + strings.Count(prev.Literal, "\n")
+
+		if tok.Line > prevLine+1 {
+			p.blank[positionOf(tok)] = true
+		}
+		if tok.Type == token.COMMENT {
+			p.comments = append(p.comments, comment{tok: tok, trailing: tok.Line == prevLine})
+		}
+	}
+	if len(all) != 0 && all[0].Type == token.COMMENT {
+		p.comments = append([]comment{{tok: all[0]}}, p.comments...)
+	}
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+	p.opened = false
+}
+
+// newline start a new line at the current indentation, after a blank line if
+// blank is set
+func (p *printer) newline(blank bool) {
+	if p.buf.Len() == 0 {
+		return
+	}
+
+	if blank && !p.opened {
+		p.buf.WriteString("\n")
+	}
+	p.buf.WriteString("\n")
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+	p.opened = false
+}
+
+// column width of the current line so far
+func (p *printer) column() int {
+	b := p.buf.Bytes()
+	line := b[bytes.LastIndexByte(b, '\n')+1:]
+	return len(line) + bytes.Count(line, []byte("\t"))*(tabWidth-1)
+}
+
+// flush print the comments that come before pos
+func (p *printer) flush(pos position) {
+	for ; p.next < len(p.comments); p.next++ {
+		c := p.comments[p.next]
+		if !positionOf(c.tok).before(pos) {
+			return
+		}
+
+		b := p.buf.Bytes()
+		if c.trailing && len(b) != 0 && b[len(b)-1] != '\n' && b[len(b)-1] != '\t' {
+			p.buf.WriteString(" ")
+		} else {
+			p.newline(p.blank[positionOf(c.tok)])
+		}
+		p.write(c.tok.Literal)
+	}
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, false)
+	p.flush(end)
+}
+
+// statements print stmts one per line, with the comments before each
+func (p *printer) statements(stmts []ast.Statement, inBlock bool) {
+	for i, stmt := range stmts {
+		start := positionOf(statementToken(stmt))
+		p.flush(start)
+		p.newline(p.blank[start])
+
+		// the last expression of a block needs no semicolon, and neither do
+		// if and try, unless the next statement would be parsed as going on
+		// with them, as in `if (x) { a } -1`
+		semicolon := !inBlock || i != len(stmts)-1
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && semicolon {
+			switch es.Expression.(type) {
+			case *ast.IfExpression, *ast.TryExpression:
+				semicolon = i+1 < len(stmts) && continues(statementToken(stmts[i+1]))
+			}
+		}
+		p.statement(stmt, semicolon)
+	}
+}
+
+// continues report whether tok can go on an expression before it
+func continues(tok token.Token) bool {
+	return parser.Precedence(tok.Type) != parser.LOWEST
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ThrowStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	default:
+		panic(fmt.Sprintf("format: unexpected statement %T", stmt))
+	}
+}
+
+// statement print stmt. Expression statements get a semicolon only when
+// semicolon is set
+func (p *printer) statement(stmt ast.Statement, semicolon bool) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.String() + " = ")
+		p.expr(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expr(stmt.ReturnValue, parser.LOWEST)
+		p.write(";")
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expr(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression, parser.LOWEST)
+		if semicolon {
+			p.write(";")
+		}
+	default:
+		panic(fmt.Sprintf("format: unexpected statement %T", stmt))
+	}
+}
+
+// block print a braced block, one statement per line
+func (p *printer) block(block *ast.BlockStatement) {
+	rbrace := positionOf(block.Rbrace)
+	if len(block.Statements) == 0 && (p.next == len(p.comments) || !positionOf(p.comments[p.next].tok).before(rbrace)) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.opened = true
+	p.indent++
+	p.statements(block.Statements, true)
+	p.flush(rbrace)
+	p.indent--
+	p.newline(false)
+	p.write("}")
+}
+
+// precedence of expression as an operand, postfix operators all bind as CALL
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(expr.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		return parser.CALL
+	default:
+		return parser.INDEX
+	}
+}
+
+// expr print expr, in parentheses if it binds looser than min
+func (p *printer) expr(expr ast.Expression, min int) {
+	if precedence(expr) < min {
+		p.write("(")
+		p.expr(expr, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.write(expr.Value)
+	case *ast.IntegerLiteral:
+		p.write(strconv.FormatInt(expr.Value, 10))
+	case *ast.StringLiteral:
+		p.write(`"` + expr.Value + `"`)
+	case *ast.Boolean:
+		p.write(strconv.FormatBool(expr.Value))
+	case *ast.PrefixExpression:
+		p.write(expr.Operator)
+		if right, ok := expr.Right.(*ast.PrefixExpression); ok && right.Operator == "-" && expr.Operator == "-" {
+			// -(-x) rather than --x
+			p.write("(")
+			p.expr(right, parser.LOWEST)
+			p.write(")")
+			break
+		}
+		p.expr(expr.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(expr)
+		p.expr(expr.Left, prec)
+		p.write(" " + expr.Operator + " ")
+		// operators associate to the left
+		p.expr(expr.Right, prec+1)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(expr.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(expr.Consequence)
+		if expr.Alternative != nil {
+			p.write(" else ")
+			p.block(expr.Alternative)
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(expr.Block)
+		if expr.Catch != nil {
+			p.write(" catch (" + expr.Param.Value + ") ")
+			p.block(expr.Catch)
+		}
+		if expr.Finally != nil {
+			p.write(" finally ")
+			p.block(expr.Finally)
+		}
+	case *ast.FunctionLiteral:
+		params := make([]string, len(expr.Parameters))
+		for i, param := range expr.Parameters {
+			params[i] = param.String()
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		if expr.ReturnType != nil {
+			p.write("-> " + expr.ReturnType.String() + " ")
+		}
+		p.block(expr.Body)
+	case *ast.CallExpression:
+		p.expr(expr.Function, parser.CALL)
+		p.list("(", ")", starts(expr.Arguments), positionOf(expr.Rparen), expressions(expr.Arguments))
+	case *ast.IndexExpression:
+		p.expr(expr.Left, parser.CALL)
+		p.write("[")
+		p.expr(expr.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expr(expr.Object, parser.CALL)
+		p.write("." + expr.Property.Value)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", starts(expr.Elements), positionOf(expr.Rbracket), expressions(expr.Elements))
+	case *ast.HashLiteral:
+		keys := expr.OrderedKeys()
+		p.list("{", "}", starts(keys), positionOf(expr.Rbrace), func(p *printer, i int) {
+			p.expr(keys[i], parser.LOWEST)
+			p.write(": ")
+			p.expr(expr.Pairs[keys[i]], parser.LOWEST)
+		})
+	case *ast.ImportExpression:
+		p.write(`import "` + expr.Path + `"`)
+	default:
+		panic(fmt.Sprintf("format: unexpected expression %T", expr))
+	}
+}
+
+// expressions print the elements of a list of expressions
+func expressions(exprs []ast.Expression) func(*printer, int) {
+	return func(p *printer, i int) {
+		p.expr(exprs[i], parser.LOWEST)
+	}
+}
+
+// starts the positions the expressions of a list start at
+func starts(exprs []ast.Expression) []position {
+	positions := make([]position, len(exprs))
+	for i, expr := range exprs {
+		positions[i] = positionOf(startToken(expr))
+	}
+	return positions
+}
+
+// startToken the leftmost token of expr
+func startToken(expr ast.Expression) token.Token {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return expr.Token
+	case *ast.IntegerLiteral:
+		return expr.Token
+	case *ast.StringLiteral:
+		return expr.Token
+	case *ast.Boolean:
+		return expr.Token
+	case *ast.PrefixExpression:
+		return expr.Token
+	case *ast.InfixExpression:
+		return startToken(expr.Left)
+	case *ast.IfExpression:
+		return expr.Token
+	case *ast.TryExpression:
+		return expr.Token
+	case *ast.FunctionLiteral:
+		return expr.Token
+	case *ast.CallExpression:
+		return startToken(expr.Function)
+	case *ast.IndexExpression:
+		return startToken(expr.Left)
+	case *ast.MemberExpression:
+		return startToken(expr.Object)
+	case *ast.ArrayLiteral:
+		return expr.Token
+	case *ast.HashLiteral:
+		return expr.Token
+	case *ast.ImportExpression:
+		return expr.Token
+	default:
+		panic(fmt.Sprintf("format: unexpected expression %T", expr))
+	}
+}
+
+// commented report whether a comment not printed yet comes before pos
+func (p *printer) commented(pos position) bool {
+	return p.next < len(p.comments) && positionOf(p.comments[p.next].tok).before(pos)
+}
+
+// list print the elements starting at starts between open and close, close
+// being at closing, separated by commas. When the first line would not fit, or
+// comments are among the elements, each element goes on a line of its own and
+// the comments where they were
+func (p *printer) list(open, close string, starts []position, closing position, elem func(p *printer, i int)) {
+	n := len(starts)
+	if !p.commented(closing) && (n == 0 || p.flat || p.fits(open, close, starts, elem)) {
+		p.write(open)
+		for i := 0; i < n; i++ {
+			if i != 0 {
+				p.write(", ")
+			}
+			elem(p, i)
+		}
+		p.write(close)
+		return
+	}
+
+	// there is no trailing comma, the parser does not accept one
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			p.write(",")
+		}
+		p.flush(starts[i])
+		p.newline(false)
+		elem(p, i)
+	}
+	p.flush(closing)
+	p.indent--
+	p.newline(false)
+	p.write(close)
+}
+
+// fits report whether the first line of the list fits in MaxWidth
+func (p *printer) fits(open, close string, starts []position, elem func(p *printer, i int)) bool {
+	flat := &printer{indent: p.indent, blank: map[position]bool{}, flat: true}
+	flat.list(open, close, starts, end, elem)
+
+	first := flat.buf.String()
+	if i := strings.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	return p.column()+len(first) <= MaxWidth
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package format
+
+import (
+	"ast"
+	"lexer"
+	"parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1", "let x = 1;\n"},
+		{"let add = fn(a,b){a+b};", "let add = fn(a, b) {\n\ta + b\n};\n"},
+		{"fn(){}", "fn() {};\n"},
+		{"puts( \"hi\" )", "puts(\"hi\");\n"},
+		{"(1 + 2) * 3 - (4 - 5)", "(1 + 2) * 3 - (4 - 5);\n"},
+		{"1 + (2 + 3)", "1 + (2 + 3);\n"},
+		{"((1 + 2) + 3)", "1 + 2 + 3;\n"},
+		{"-(1 + 2)", "-(1 + 2);\n"},
+		{"-(-1)", "-(-1);\n"},
+		{"!!true", "!!true;\n"},
+		{"(fn(x){x})(1)", "fn(x) {\n\tx\n}(1);\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{"f(x)[0].y", "f(x)[0].y;\n"},
+		{"{\"b\": 1, \"a\": [1,2]}", "{\"b\": 1, \"a\": [1, 2]};\n"},
+		{"let m = import \"lib\"; m.f(1)", "let m = import \"lib\";\nm.f(1);\n"},
+		{"let xs:array<int>=[]", "let xs: array<int> = [];\n"},
+		{"fn(a:string,b:hash<string,int>)->bool{true}", "fn(a: string, b: hash<string, int>) -> bool {\n\ttrue\n};\n"},
+		{
+			"if(x<1){1}else{2}",
+			"if (x < 1) {\n\t1\n} else {\n\t2\n}\n",
+		},
+		{
+			"if (x) { 1 }; -1",
+			"if (x) {\n\t1\n};\n-1;\n",
+		},
+		{
+			"try { throw 1 } catch (e) { e } finally { puts(1) }",
+			"try {\n\tthrow 1;\n} catch (e) {\n\te\n} finally {\n\tputs(1)\n}\n",
+		},
+		{
+			"let x = 1;\n\n\n\nlet y = 2;",
+			"let x = 1;\n\nlet y = 2;\n",
+		},
+		{
+			"fn() {\n\n  let x = 1;\n\n  x\n\n}",
+			"fn() {\n\tlet x = 1;\n\n\tx\n};\n",
+		},
+		{
+			"let long = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree);",
+			"let long = someFunction(\n\targumentNumberOne,\n\targumentNumberTwo,\n\targumentNumberThree\n);\n",
+		},
+		{
+			"map([1, 2, 3], fn(x) { x * 2 })",
+			"map([1, 2, 3], fn(x) {\n\tx * 2\n});\n",
+		},
+		{"#!/usr/bin/env monkey\nlet x=1", "#!/usr/bin/env monkey\nlet x = 1;\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+
+		if string(formatted) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// leading
+let x = 1;   // trailing
+
+// before f
+let f = fn() { // opening
+  // inside
+  x
+
+  // closing
+};
+let e = fn() {
+  // only
+};
+let a = [1, // one
+  2];
+// end`
+
+	expected := `// leading
+let x = 1; // trailing
+
+// before f
+let f = fn() { // opening
+	// inside
+	x
+
+	// closing
+};
+let e = fn() {
+	// only
+};
+let a = [
+	1, // one
+	2
+];
+// end
+`
+
+	formatted, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+	if string(formatted) != expected {
+		t.Errorf("comments wrong.\nexpected=%q\ngot=%q", expected, formatted)
+	}
+}
+
+// TestSourceListComments checks comments among the elements of a list stay
+// where they are, the list broken one element per line
+func TestSourceListComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let w = [\n// lead\n1\n];", "let w = [\n\t// lead\n\t1\n];\n"},
+		{"f(1, // one\n2)", "f(\n\t1, // one\n\t2\n);\n"},
+		{"f(1 // one\n, 2)", "f(\n\t1, // one\n\t2\n);\n"},
+		{"let h = {\"a\": 1,\n// b\n\"b\": [2, // two\n3]};", "let h = {\n\t\"a\": 1,\n\t// b\n\t\"b\": [\n\t\t2, // two\n\t\t3\n\t]\n};\n"},
+		{"g(1 // last\n)", "g(\n\t1 // last\n);\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", formatted, err)
+			continue
+		}
+		if string(again) != string(formatted) {
+			t.Errorf("Source is not idempotent.\nfirst=%q\nsecond=%q", formatted, again)
+		}
+	}
+}
+
+// TestSourceRoundTrip checks formatting keeps the program the same and is
+// idempotent
+func TestSourceRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let x = 5 * (2 + -3) / f(a, b)[1];",
+		"let h = {1: [true, false], \"k\": fn(a) { return a; }, \"n\": {}};",
+		"if (a == b) { c } else { if (!d) { -e } }",
+		"let r = try { g(1) } catch (err) { throw err.message; };",
+		"let m = import \"x\"; m.f(m.g)",
+		"a - (b - (c - d)) * (e + f) < g == (h != i)",
+		"let xs = [aVeryLongIdentifierName, anotherVeryLongIdentifierName, yetAnotherOne, [1, 2, 3]];",
+		"if (x) { 1 } - 1",
+	}
+
+	for _, input := range inputs {
+		formatted, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", input, err)
+			continue
+		}
+
+		if expected, got := parse(t, input), parse(t, string(formatted)); !ast.Equal(expected, got) {
+			t.Errorf("Source(%q) changed the program.\nexpected=%q\ngot=%q", input, expected, got)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", formatted, err)
+			continue
+		}
+		if string(again) != string(formatted) {
+			t.Errorf("Source is not idempotent.\nfirst=%q\nsecond=%q", formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source([]byte("let = 1;"))
+
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("err is not *ParseError. got=%T (%v)", err, err)
+	}
+	if !strings.Contains(perr.Error(), "expected next token to be IDENT") {
+		t.Errorf("wrong error message. got=%q", perr.Error())
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parser.New(lexer.New("let x = fn(a) { a + 1 }")).ParseProgram()
+
+	expected := "let x = fn(a) {\n\ta + 1\n};"
+	if got := Node(program); got != expected {
+		t.Errorf("Node wrong. expected=%q, got=%q", expected, got)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFmt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	formatted := filepath.Join(dir, "formatted.mk")
+	messy := filepath.Join(dir, "messy.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	for path, src := range map[string]string{
+		formatted: "let x = 1;\n",
+		messy:     "let x=1",
+		broken:    "let = 1",
+	} {
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"fmt"}, "let  y=2", 0, "let y = 2;\n", ""},
+		{[]string{"fmt", messy}, "", 0, "let x = 1;\n", ""},
+		{[]string{"fmt", "-check", formatted}, "", 0, "", ""},
+		{[]string{"fmt", "--check", formatted, messy}, "", 1, messy + "\n", ""},
+		{[]string{"fmt", broken}, "", exitUsage, "", broken + ": parse error: expected next token to be IDENT"},
+		{[]string{"fmt", "-check", "-w", messy}, "", exitUsage, "", "usage: monkey fmt"},
+		{[]string{"fmt", "-w"}, "", exitUsage, "", "cannot use -w with standard input"},
+		{[]string{"fmt", "-w", messy}, "", 0, "", ""},
+		{[]string{"fmt", "-check", messy}, "", 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("run(%q) stdout wrong. expected=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("run(%q) stderr wrong. expected to contain %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
@@ -5,7 +5,10 @@
 
 package lexer
 
-import "token"
+import (
+	"strings"
+	"token"
+)
 
 // Lexer core lexer structure
 type Lexer struct {
@@ -13,11 +16,28 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current ch)
 	ch           byte // current char under examination
+	line         int  // line of current char, 1-based
+	column       int  // column of current char, 1-based
+
+	comments []token.Token
+}
+
+// SkipShebang blank out a leading #! line of src, keeping the newline so
+// positions in error messages still match the file
+func SkipShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return src[i:]
+	}
+	return ""
 }
 
 // New initialize a new Lexer instance
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -28,12 +48,23 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, ok := l.readString()
+		if ok {
+			tok.Type = token.STRING
+			tok.Literal = literal
+		} else {
+			tok.Type = token.ILLEGAL
+			tok.Literal = "\"" + literal
+			tok.Line, tok.Column = line, column
+			// the input is exhausted, there is no closing quote to skip
+			return tok
+		}
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -53,7 +84,13 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -70,6 +107,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -85,6 +124,7 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			// it's necessary, because rreadIdentifier will advance readPosition
 			// internally
 			return tok
@@ -92,6 +132,7 @@ func (l *Lexer) NextToken() token.Token {
 		if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			// same reason as above
 			return tok
 		}
@@ -100,6 +141,7 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.readChar()
 
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -112,6 +154,12 @@ func isDigit(ch byte) bool {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -144,22 +192,51 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString read a string literal, reporting false if the input ends before
+// the closing quote
+func (l *Lexer) readString() (string, bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
-		// FIXME: if not? infinite reading?
 		if l.ch == '"' {
-			break
+			return l.input[position:l.position], true
+		}
+		if l.ch == 0 {
+			return l.input[position:l.position], false
 		}
 	}
-	return l.input[position:l.position]
 }
 
+// skipWhitespace skip whitespace and comments, which run from // to the end
+// of the line
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	tok.Literal = strings.TrimRight(l.input[position:l.position], " \t\r")
+
+	l.comments = append(l.comments, tok)
+}
+
+// Comments return the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
 }
 
 func newToken(tokenType token.Type, ch byte) token.Token {
@@ -37,6 +37,12 @@ func TestNextToken(t *testing.T) {
 
 	{"foo": "bar"}
 
+	try { throw 1; } catch (e) {} finally {}
+
+	import "m".x
+
+	fn() -> int {}
+
 	&
 	`
 
@@ -161,6 +167,38 @@ func TestNextToken(t *testing.T) {
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
 
+		// try { throw 1; } catch (e) {} finally {}
+		{token.TRY, "try"},
+		{token.LBRACE, "{"},
+		{token.THROW, "throw"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.LPAREN, "("},
+		{token.IDENT, "e"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.FINALLY, "finally"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+
+		// import "m".x
+		{token.IMPORT, "import"},
+		{token.STRING, "m"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+
+		// fn() -> int {}
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+
 		// &
 		{token.ILLEGAL, "&"},
 
@@ -181,3 +219,117 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let x = 5;
+  x + "ab";`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"ab", 2, 7},
+		{";", 2, 11},
+		{"", 2, 12},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := New(`let s = "abc`)
+
+	for _, expected := range []token.Type{token.LET, token.IDENT, token.ASSIGN} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("tokentype wrong. expected=%q, got=%q", expected, tok.Type)
+		}
+	}
+
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != `"abc` {
+		t.Fatalf("unterminated string wrong. got=%+v", tok)
+	}
+
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF after unterminated string. got=%+v", tok)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// first
+let x = 10 / 2; // second
+x`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("wrong number of comments. got=%d", len(comments))
+	}
+	if comments[0].Literal != "// first" || comments[0].Line != 1 || comments[0].Column != 1 {
+		t.Errorf("comments[0] wrong. got=%+v", comments[0])
+	}
+	if comments[1].Literal != "// second" || comments[1].Line != 2 || comments[1].Column != 17 {
+		t.Errorf("comments[1] wrong. got=%+v", comments[1])
+	}
+}
+
+func TestSkipShebang(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#!/usr/bin/env monkey\nlet x = 1;", "\nlet x = 1;"},
+		{"#!monkey", ""},
+		{"let x = 1;", "let x = 1;"},
+	}
+
+	for _, tt := range tests {
+		if got := SkipShebang(tt.input); got != tt.expected {
+			t.Errorf("SkipShebang(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
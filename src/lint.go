@@ -0,0 +1,87 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lexer"
+	"lint"
+	"strings"
+)
+
+const lintUsage = `usage: monkey lint [-disable rules] [-globals names] [file ...]
+       monkey lint -rules
+
+Report likely mistakes in monkey source files, or standard input when there
+are none, as file:line:column: message (rule). Exit with status 1 when there
+are findings.
+
+Suppress a finding with a "// lint:ignore rule reason" comment on its line or
+the line before, or in a whole file with "// lint:file-ignore rule reason".
+
+flags:
+`
+
+// runLint the lint subcommand
+func runLint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, lintUsage)
+		flags.PrintDefaults()
+	}
+	disable := flags.String("disable", "", "comma separated `rules` not to report")
+	globals := flags.String("globals", "", "comma separated `names` defined by the host program")
+	rules := flags.Bool("rules", false, "list the rules and exit")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *rules {
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(stdout, "%-20s %s\n", rule.ID, rule.Description)
+		}
+		return 0
+	}
+
+	cfg := &lint.Config{Disable: splitList(*disable), Globals: splitList(*globals)}
+
+	return eachSource("lint", flags.Args(), stdin, stderr, func(path string, src []byte) int {
+		return lintSource(path, string(src), cfg, stdout, stderr)
+	})
+}
+
+func lintSource(path string, src string, cfg *lint.Config, stdout io.Writer, stderr io.Writer) int {
+	findings, err := lint.Source(lexer.SkipShebang(src), cfg)
+	if err != nil {
+		for _, msg := range err.(*lint.ParseError).Errors {
+			fmt.Fprintf(stderr, "%s: parse error: %s\n", path, msg)
+		}
+		return exitUsage
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s:%s\n", path, f)
+	}
+	if len(findings) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// splitList split a comma separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
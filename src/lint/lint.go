@@ -0,0 +1,437 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package lint reports common mistakes in monkey programs without running
+// them.
+//
+// Findings can be suppressed with comments. A comment of the form
+//
+//	// lint:ignore rule[,rule...] [reason]
+//
+// suppresses the named rules on its own line and on the line after it, and
+//
+//	// lint:file-ignore rule[,rule...] [reason]
+//
+// suppresses them in the whole file.
+package lint
+
+import (
+	"ast"
+	"evaluator"
+	"fmt"
+	"lexer"
+	"parser"
+	"sort"
+	"strings"
+	"token"
+)
+
+// Rule IDs, stable across releases so they can be used in suppressions
+const (
+	UnusedBinding     = "unused-binding"
+	UnusedParameter   = "unused-parameter"
+	ShadowedBuiltin   = "shadowed-builtin"
+	Unreachable       = "unreachable"
+	Undefined         = "undefined"
+	NotCallable       = "not-callable"
+	ConstantCondition = "constant-condition"
+)
+
+// Rule a check the linter makes
+type Rule struct {
+	ID          string
+	Description string
+}
+
+// Rules every rule, in the order they are documented
+var Rules = []Rule{
+	{UnusedBinding, "let binding inside a function or catch block that is never used"},
+	{UnusedParameter, "function parameter that is never used"},
+	{ShadowedBuiltin, "let binding or parameter that hides a builtin function"},
+	{Unreachable, "statement after return or throw, which never runs"},
+	{Undefined, "identifier that is not defined anywhere in scope"},
+	{NotCallable, "call of a literal or operator result, which is never a function"},
+	{ConstantCondition, "if condition that does not depend on anything"},
+}
+
+// Finding a problem found in a program
+type Finding struct {
+	Rule    string
+	Message string
+	Line    int
+	Column  int
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", f.Line, f.Column, f.Message, f.Rule)
+}
+
+// Config what the linter checks
+type Config struct {
+	// Disable rule IDs not to report
+	Disable []string
+	// Globals names defined by the host, through monkey.Interpreter.Set or
+	// Register for instance, that are not undefined
+	Globals []string
+}
+
+// ParseError the source could not be parsed, so it was not linted
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Source lint the monkey program src, honouring lint:ignore comments. A nil
+// config checks everything
+func Source(src string, cfg *Config) ([]Finding, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	findings := Program(program, cfg)
+
+	s := parseSuppressions(l.Comments())
+	kept := findings[:0]
+	for _, f := range findings {
+		if !s.suppressed(f) {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
+
+// Program lint program, sorting the findings by position. A nil config checks
+// everything
+func Program(program *ast.Program, cfg *Config) []Finding {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	l := &linter{
+		disabled: map[string]bool{},
+		globals:  map[string]bool{},
+		builtins: map[string]bool{},
+	}
+	for _, id := range cfg.Disable {
+		l.disabled[id] = true
+	}
+	for _, name := range cfg.Globals {
+		l.globals[name] = true
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		l.builtins[name] = true
+	}
+
+	l.openScope(program, false)
+	ast.Walk(l, program)
+	l.closeScope()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.findings
+}
+
+// binding a name declared by let, as a parameter or by catch
+type binding struct {
+	tok  token.Token
+	kind string // UnusedBinding or UnusedParameter, "" when never reported
+	used bool
+}
+
+type scope struct {
+	parent *scope
+	names  map[string]*binding
+}
+
+type linter struct {
+	disabled map[string]bool
+	globals  map[string]bool
+	builtins map[string]bool
+
+	scope    *scope
+	findings []Finding
+}
+
+func (l *linter) report(rule string, tok token.Token, format string, args ...interface{}) {
+	if l.disabled[rule] {
+		return
+	}
+	l.findings = append(l.findings, Finding{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+// openScope start a scope and declare the let bindings made directly in body,
+// wherever they are, since a function may use a binding made after it. Let
+// bindings in if blocks belong to the enclosing scope, as when evaluating.
+// Unused bindings are reported unless the scope is the top level, whose
+// bindings are the exports of a module
+func (l *linter) openScope(body ast.Node, reportUnused bool) {
+	l.scope = &scope{parent: l.scope, names: map[string]*binding{}}
+
+	var declare func(node ast.Node) bool
+	declare = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			// the catch block is a scope of its own
+			ast.Inspect(node.Block, declare)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, declare)
+			}
+			return false
+		case *ast.LetStatement:
+			kind := UnusedBinding
+			if !reportUnused {
+				kind = ""
+			}
+			l.declare(node.Name, kind)
+		}
+		return true
+	}
+	ast.Inspect(body, declare)
+}
+
+// declare add ident to the current scope
+func (l *linter) declare(ident *ast.Identifier, kind string) {
+	if l.builtins[ident.Value] {
+		l.report(ShadowedBuiltin, ident.Token, "%s shadows the builtin function %s", ident.Value, ident.Value)
+	}
+	if _, ok := l.scope.names[ident.Value]; ok {
+		// a second let of the same name rebinds it
+		return
+	}
+	l.scope.names[ident.Value] = &binding{tok: ident.Token, kind: kind}
+}
+
+// closeScope report the unused bindings of the current scope and leave it
+func (l *linter) closeScope() {
+	names := make([]string, 0, len(l.scope.names))
+	for name := range l.scope.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b := l.scope.names[name]
+		if b.used || b.kind == "" || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if b.kind == UnusedParameter {
+			l.report(UnusedParameter, b.tok, "parameter %s is never used", name)
+		} else {
+			l.report(UnusedBinding, b.tok, "%s is bound but never used", name)
+		}
+	}
+
+	l.scope = l.scope.parent
+}
+
+// use resolve a reference to ident
+func (l *linter) use(ident *ast.Identifier) {
+	for s := l.scope; s != nil; s = s.parent {
+		if b, ok := s.names[ident.Value]; ok {
+			b.used = true
+			return
+		}
+	}
+
+	if l.builtins[ident.Value] || l.globals[ident.Value] {
+		return
+	}
+	l.report(Undefined, ident.Token, "%s is not defined", ident.Value)
+}
+
+// Visit check node. Nodes that declare names or open scopes walk their own
+// children, so that only references reach the *ast.Identifier case
+func (l *linter) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Program:
+		l.unreachable(node.Statements)
+	case *ast.BlockStatement:
+		l.unreachable(node.Statements)
+	case *ast.Identifier:
+		l.use(node)
+	case *ast.LetStatement:
+		if node.Value != nil {
+			ast.Walk(l, node.Value)
+		}
+		return nil
+	case *ast.MemberExpression:
+		// the property is a name in the object, not a reference
+		ast.Walk(l, node.Object)
+		return nil
+	case *ast.FunctionLiteral:
+		l.openScope(node.Body, true)
+		for _, param := range node.Parameters {
+			l.declare(param, UnusedParameter)
+		}
+		ast.Walk(l, node.Body)
+		l.closeScope()
+		return nil
+	case *ast.TryExpression:
+		ast.Walk(l, node.Block)
+		if node.Catch != nil {
+			l.openScope(node.Catch, true)
+			// the catch parameter cannot be left out, so it is never reported
+			l.declare(node.Param, "")
+			ast.Walk(l, node.Catch)
+			l.closeScope()
+		}
+		if node.Finally != nil {
+			ast.Walk(l, node.Finally)
+		}
+		return nil
+	case *ast.CallExpression:
+		if !callable(node.Function) {
+			l.report(NotCallable, node.Token, "%s is not a function", describe(node.Function))
+		}
+	case *ast.IfExpression:
+		if constant(node.Condition) {
+			l.report(ConstantCondition, node.Token, "if condition %s is constant", node.Condition.String())
+		}
+	}
+	return l
+}
+
+// unreachable report the first statement after a return or throw
+func (l *linter) unreachable(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			if i+1 < len(stmts) {
+				l.report(Unreachable, statementToken(stmts[i+1]), "unreachable code after %s", stmt.TokenLiteral())
+			}
+			return
+		}
+	}
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ThrowStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	default:
+		panic(fmt.Sprintf("lint: unexpected statement %T", stmt))
+	}
+}
+
+// callable report whether expr may evaluate to a function. Literals other
+// than functions, and the results of operators, never do
+func callable(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.ArrayLiteral,
+		*ast.HashLiteral, *ast.PrefixExpression, *ast.InfixExpression, *ast.ImportExpression:
+		return false
+	}
+	return true
+}
+
+func describe(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return "integer " + expr.String()
+	case *ast.StringLiteral:
+		return fmt.Sprintf("string %q", expr.Value)
+	case *ast.Boolean:
+		return "boolean " + expr.String()
+	case *ast.ArrayLiteral:
+		return "array literal"
+	case *ast.HashLiteral:
+		return "hash literal"
+	case *ast.ImportExpression:
+		return "module " + expr.Path
+	default:
+		return expr.String()
+	}
+}
+
+// constant report whether expr is made of literals only
+func constant(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return constant(expr.Right)
+	case *ast.InfixExpression:
+		return constant(expr.Left) && constant(expr.Right)
+	case *ast.ArrayLiteral:
+		for _, elem := range expr.Elements {
+			if !constant(elem) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashLiteral:
+		for key, value := range expr.Pairs {
+			if !constant(key) || !constant(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// suppressions lint:ignore and lint:file-ignore comments
+type suppressions struct {
+	file  map[string]bool
+	lines map[int]map[string]bool
+}
+
+func parseSuppressions(comments []token.Token) *suppressions {
+	s := &suppressions{file: map[string]bool{}, lines: map[int]map[string]bool{}}
+
+	for _, c := range comments {
+		fields := strings.Fields(strings.TrimPrefix(c.Literal, "//"))
+		if len(fields) < 2 {
+			continue
+		}
+
+		rules := strings.Split(fields[1], ",")
+		switch fields[0] {
+		case "lint:file-ignore":
+			for _, rule := range rules {
+				s.file[rule] = true
+			}
+		case "lint:ignore":
+			for _, line := range []int{c.Line, c.Line + 1} {
+				if s.lines[line] == nil {
+					s.lines[line] = map[string]bool{}
+				}
+				for _, rule := range rules {
+					s.lines[line][rule] = true
+				}
+			}
+		}
+	}
+
+	return s
+}
+
+func (s *suppressions) suppressed(f Finding) bool {
+	return s.file[f.Rule] || s.lines[f.Line][f.Rule]
+}
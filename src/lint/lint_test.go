@@ -0,0 +1,138 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		// top level bindings are the exports of a module
+		{"let x = 1;", nil},
+		{
+			"let f = fn(a, b) { let c = 1; a };",
+			[]string{
+				"1:15: parameter b is never used (unused-parameter)",
+				"1:24: c is bound but never used (unused-binding)",
+			},
+		},
+		{"let f = fn(_a, b) { let _c = 1; b };", nil},
+		{"let f = fn() { if (true) { let y = 1; } 2 };", []string{"1:16: if condition true is constant (constant-condition)", "1:32: y is bound but never used (unused-binding)"}},
+		{"try { 1 } catch (e) { 2 }", nil},
+		{"try { 1 } catch (e) { let m = 1; 2 }", []string{"1:27: m is bound but never used (unused-binding)"}},
+		{
+			"let len = fn(first) { first };",
+			[]string{
+				"1:5: len shadows the builtin function len (shadowed-builtin)",
+				"1:14: first shadows the builtin function first (shadowed-builtin)",
+			},
+		},
+		{
+			"let f = fn() { return 1; puts(2); puts(3) }; f",
+			[]string{"1:26: unreachable code after return (unreachable)"},
+		},
+		{"throw 1; 2", []string{"1:10: unreachable code after throw (unreachable)"}},
+		{"puts(y)", []string{"1:6: y is not defined (undefined)"}},
+		{"let f = fn() { g() }; let g = fn() { f() };", nil},
+		{"let f = fn(n) { if (n < 1) { 0 } else { f(n - 1) } };", nil},
+		{"let m = import \"lib\"; m.anything", nil},
+		{"try { 1 } catch (e) { e }; e", []string{"1:28: e is not defined (undefined)"}},
+		{"5()", []string{"1:2: integer 5 is not a function (not-callable)"}},
+		{"\"f\"(1); [1](); (1 + 2)()", []string{
+			"1:4: string \"f\" is not a function (not-callable)",
+			"1:12: array literal is not a function (not-callable)",
+			"1:23: (1 + 2) is not a function (not-callable)",
+		}},
+		{"fn(x) { x }(1); let f = fn() { 1 }; f()", nil},
+		{"if (1 < 2) { 3 }", []string{"1:1: if condition (1 < 2) is constant (constant-condition)"}},
+		{"let x = 1; if (x < 2) { 3 }", nil},
+	}
+
+	for _, tt := range tests {
+		findings, err := Source(tt.input, nil)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+
+		var got []string
+		for _, f := range findings {
+			got = append(got, f.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	input := `// lint:file-ignore shadowed-builtin old code
+let len = 1;
+puts(a); // lint:ignore undefined set by the host
+// lint:ignore undefined,not-callable
+puts(b); 5();
+puts(c);
+// lint:ignore unreachable
+puts(d);`
+
+	findings, err := Source(input, nil)
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("wrong number of findings. got=%v", findings)
+	}
+	if findings[0].Line != 6 || findings[0].Rule != Undefined {
+		t.Errorf("findings[0] wrong. got=%s", findings[0])
+	}
+	if findings[1].Line != 8 || findings[1].Rule != Undefined {
+		t.Errorf("findings[1] wrong. got=%s", findings[1])
+	}
+}
+
+func TestConfig(t *testing.T) {
+	findings, err := Source("let len = 1; host(x);", &Config{
+		Disable: []string{ShadowedBuiltin},
+		Globals: []string{"host"},
+	})
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+
+	if len(findings) != 1 || findings[0].Rule != Undefined || !strings.Contains(findings[0].Message, "x") {
+		t.Errorf("wrong findings. got=%v", findings)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Source("let = 1;", nil)
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("err is not *ParseError. got=%T (%v)", err, err)
+	}
+}
+
+func TestRulesDocumented(t *testing.T) {
+	seen := map[string]bool{}
+	for _, rule := range Rules {
+		if seen[rule.ID] || rule.Description == "" {
+			t.Errorf("rule %q duplicated or undocumented", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+
+	for _, id := range []string{UnusedBinding, UnusedParameter, ShadowedBuiltin, Unreachable, Undefined, NotCallable, ConstantCondition} {
+		if !seen[id] {
+			t.Errorf("rule %q missing from Rules", id)
+		}
+	}
+}
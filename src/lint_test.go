@@ -0,0 +1,89 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"lint"
+	"strings"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	tests := []struct {
+		flags          []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+	}{
+		{nil, "let x = 1; x", 0, ""},
+		{nil, "#!monkey\nputs(y)", 1, "<stdin>:2:6: y is not defined (undefined)\n"},
+		{[]string{"-globals", "y"}, "puts(y)", 0, ""},
+		{[]string{"-disable", "undefined, not-callable"}, "y(); 5()", 0, ""},
+		{[]string{"-disable", "undefined"}, "y(); 5()", 1, "<stdin>:1:7: integer 5 is not a function (not-callable)\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"lint"}, tt.flags...)
+		code := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("lint %q of %q exit code wrong. expected=%d, got=%d", tt.flags, tt.stdin, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("lint %q of %q stdout wrong. expected=%q, got=%q", tt.flags, tt.stdin, tt.expectedStdout, stdout.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("lint %q of %q wrote to stderr: %q", tt.flags, tt.stdin, stderr.String())
+		}
+	}
+}
+
+func TestRunLintErrors(t *testing.T) {
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedStderr string
+	}{
+		{[]string{"lint"}, "let = 1", "<stdin>: parse error: expected next token to be IDENT, got = instead\n" +
+			"<stdin>: parse error: no prefix parse function for = found\n"},
+		{[]string{"lint", "missing.mk"}, "", "monkey lint: open missing.mk: no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != exitUsage {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, exitUsage, code)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("run(%q) wrote to stdout: %q", tt.args, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("run(%q) stderr wrong. expected=%q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestRunLintRules(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lint", "-rules"}, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("lint -rules exit code wrong. expected=0, got=%d", code)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != len(lint.Rules) {
+		t.Fatalf("lint -rules lists %d rules, expected %d. got=%q", len(lines), len(lint.Rules), stdout.String())
+	}
+	for i, rule := range lint.Rules {
+		fields := strings.Fields(lines[i])
+		if len(fields) < 2 || fields[0] != rule.ID || !strings.HasSuffix(lines[i], " "+rule.Description) {
+			t.Errorf("line %d of lint -rules wrong. expected %s and its description, got=%q", i+1, rule.ID, lines[i])
+		}
+	}
+}
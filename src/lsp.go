@@ -0,0 +1,49 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lsp"
+)
+
+const lspUsage = `usage: monkey lsp [-globals names]
+
+Run a Language Server Protocol server on standard input and output, for
+editors to show diagnostics, hover information, definitions, document symbols
+and completions for monkey files.
+
+flags:
+`
+
+// runLSP the lsp subcommand
+func runLSP(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, lspUsage)
+		flags.PrintDefaults()
+	}
+	globals := flags.String("globals", "", "comma separated `names` defined by the host program")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	s := lsp.NewServer(stdin, stdout)
+	s.Globals = splitList(*globals)
+	if err := s.Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
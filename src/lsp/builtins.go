@@ -0,0 +1,26 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package lsp
+
+// builtinDoc signature and description of a builtin function
+type builtinDoc struct {
+	signature string
+	doc       string
+}
+
+// builtinDocs what hover and completion show for each builtin function
+var builtinDocs = map[string]builtinDoc{
+	"len":   {"len(value)", "Number of characters of a string or elements of an array."},
+	"first": {"first(array)", "First element of array, null if it is empty."},
+	"last":  {"last(array)", "Last element of array, null if it is empty."},
+	"rest":  {"rest(array)", "New array of the elements of array but the first, null if it is empty."},
+	"push":  {"push(array, value)", "New array of the elements of array followed by value."},
+	"puts":  {"puts(value, ...)", "Print each value on a line of its own and return null."},
+	"json_parse": {"json_parse(string)",
+		"Value of the JSON text string: objects become hashes, arrays arrays, numbers integers or floats."},
+	"json_stringify": {"json_stringify(value[, indent])",
+		"JSON text of value, indented per level by indent spaces, 0 to 10, or by the indent string of up to 10 spaces or tabs, when given. Members of hashes are sorted by key."},
+}
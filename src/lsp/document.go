@@ -0,0 +1,496 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package lsp
+
+import (
+	"ast"
+	"check"
+	"evaluator"
+	"lexer"
+	"lint"
+	"parser"
+	"sort"
+	"strings"
+	"token"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// binding kinds, as shown by hover
+const (
+	kindLet       = "let"
+	kindParameter = "parameter"
+	kindCatch     = "catch parameter"
+	kindBuiltin   = "builtin"
+	kindGlobal    = "global"
+)
+
+// pos 1-based line and byte column, as in tokens
+type pos struct {
+	line, column int
+}
+
+func tokenPos(tok token.Token) pos {
+	return pos{tok.Line, tok.Column}
+}
+
+func (p pos) before(other pos) bool {
+	return p.line < other.line || p.line == other.line && p.column < other.column
+}
+
+// binding a name bound by let, as a parameter or by catch, or a builtin or
+// global
+type binding struct {
+	kind string
+	// ident the name where it is bound, nil for builtins and globals
+	ident *ast.Identifier
+	// value the value of a let
+	value ast.Expression
+}
+
+// scope names bound in the source from start up to end: a function, a catch
+// block or the whole document
+type scope struct {
+	parent     *scope
+	start, end pos
+	// names the bindings of each name, in source order, the same name being
+	// bound again by a second let
+	names    map[string][]*binding
+	children []*scope
+}
+
+// lookup the binding a reference at p refers to: the last one made before p
+// in the innermost scope binding the name, or its first one if all are made
+// after p, which a function may use
+func (s *scope) lookup(name string, p pos) *binding {
+	for ; s != nil; s = s.parent {
+		bindings := s.names[name]
+		if len(bindings) == 0 {
+			continue
+		}
+		found := bindings[0]
+		for _, b := range bindings[1:] {
+			if b.ident != nil && tokenPos(b.ident.Token).before(p) {
+				found = b
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+// innermost the deepest scope under s holding p
+func (s *scope) innermost(p pos) *scope {
+	for _, child := range s.children {
+		if !p.before(child.start) && p.before(child.end) {
+			return child.innermost(p)
+		}
+	}
+	return s
+}
+
+// analysis what is known about a program that parsed
+type analysis struct {
+	program *ast.Program
+	// idents the identifiers that bind or refer to a binding, with it
+	idents   []*ast.Identifier
+	bindings map[*ast.Identifier]*binding
+	root     *scope
+	// types inferred by the checker, by identifier
+	types map[*ast.Identifier]string
+	// braces the position after the closing brace of each block, by the
+	// position of its opening brace
+	braces map[pos]pos
+}
+
+// document an open text document
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	diagnostics []Diagnostic
+	// analysis of the last version of the document that parsed, nil if
+	// none did
+	analysis *analysis
+}
+
+// update replace the text of d and analyse it again. If it does not parse,
+// the analysis of the last version that did is kept, so that hover,
+// definition and completion go on working while an edit is in progress
+func (d *document) update(text string, globals []string) {
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+	d.diagnostics = []Diagnostic{}
+
+	src := lexer.SkipShebang(text)
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for i, msg := range p.Errors() {
+			tok := p.ErrorTokens()[i]
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.tokenRange(tok.Line, tok.Column, len(tok.Literal)),
+				Severity: SeverityError,
+				Source:   "monkey",
+				Message:  msg,
+			})
+		}
+		return
+	}
+
+	findings, _ := lint.Source(src, &lint.Config{Globals: globals})
+	for _, f := range findings {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.wordRange(f.Line, f.Column),
+			Severity: SeverityWarning,
+			Code:     f.Rule,
+			Source:   "monkey lint",
+			Message:  f.Message,
+		})
+	}
+
+	d.analysis = analyze(src, program, globals)
+}
+
+// analyze resolve the names of program, which parsed from src
+func analyze(src string, program *ast.Program, globals []string) *analysis {
+	a := &analysis{
+		program:  program,
+		bindings: map[*ast.Identifier]*binding{},
+		braces:   matchBraces(src),
+		types:    check.Program(program, &check.Config{Globals: globals}).Types,
+	}
+
+	end := pos{1 << 30, 0}
+	universe := &scope{start: pos{1, 1}, end: end, names: map[string][]*binding{}}
+	for _, name := range evaluator.BuiltinNames() {
+		universe.names[name] = []*binding{{kind: kindBuiltin}}
+	}
+	for _, name := range globals {
+		universe.names[name] = []*binding{{kind: kindGlobal}}
+	}
+
+	r := &resolver{a: a, scope: universe}
+	a.root = r.openScope(pos{1, 1}, end, nil, kindParameter, program)
+	ast.Walk(r, program)
+	r.closeScope()
+
+	return a
+}
+
+// matchBraces find the closing brace of each opening one in src. Braces left
+// open close at the end of the source
+func matchBraces(src string) map[pos]pos {
+	braces := map[pos]pos{}
+	var open []pos
+
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tokenPos(tok))
+		case token.RBRACE:
+			if len(open) != 0 {
+				braces[open[len(open)-1]] = pos{tok.Line, tok.Column + 1}
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return braces
+}
+
+// end the position after the block opened at start
+func (a *analysis) end(start token.Token) pos {
+	if end, ok := a.braces[tokenPos(start)]; ok {
+		return end
+	}
+	return pos{1 << 30, 0}
+}
+
+// resolver walk a program, building its scopes and binding each identifier
+type resolver struct {
+	a     *analysis
+	scope *scope
+}
+
+// openScope start a scope from start up to end, binding params and the let
+// bindings made directly in body, that is not in nested functions or catch
+// blocks
+func (r *resolver) openScope(start, end pos, params []*ast.Identifier, kind string, body ast.Node) *scope {
+	s := &scope{parent: r.scope, start: start, end: end, names: map[string][]*binding{}}
+	r.scope.children = append(r.scope.children, s)
+	r.scope = s
+
+	for _, param := range params {
+		r.bind(param, &binding{kind: kind, ident: param})
+	}
+
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		case *ast.LetStatement:
+			r.bind(node.Name, &binding{kind: kindLet, ident: node.Name, value: node.Value})
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+
+	return s
+}
+
+func (r *resolver) closeScope() {
+	r.scope = r.scope.parent
+}
+
+// bind add b to the current scope, ident being where it is bound
+func (r *resolver) bind(ident *ast.Identifier, b *binding) {
+	r.scope.names[ident.Value] = append(r.scope.names[ident.Value], b)
+	r.a.idents = append(r.a.idents, ident)
+	r.a.bindings[ident] = b
+}
+
+// Visit resolve the references under node. Nodes that bind names or open
+// scopes walk their own children, so that only references reach the
+// *ast.Identifier case
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		if b := r.scope.lookup(node.Value, tokenPos(node.Token)); b != nil {
+			r.a.idents = append(r.a.idents, node)
+			r.a.bindings[node] = b
+		}
+	case *ast.LetStatement:
+		if node.Value != nil {
+			ast.Walk(r, node.Value)
+		}
+		return nil
+	case *ast.MemberExpression:
+		// the property is a name in the object, not a reference
+		ast.Walk(r, node.Object)
+		return nil
+	case *ast.FunctionLiteral:
+		r.openScope(tokenPos(node.Token), r.a.end(node.Body.Token), node.Parameters, kindParameter, node.Body)
+		ast.Walk(r, node.Body)
+		r.closeScope()
+		return nil
+	case *ast.TryExpression:
+		ast.Walk(r, node.Block)
+		if node.Catch != nil {
+			params := []*ast.Identifier{node.Param}
+			r.openScope(tokenPos(node.Param.Token), r.a.end(node.Catch.Token), params, kindCatch, node.Catch)
+			ast.Walk(r, node.Catch)
+			r.closeScope()
+		}
+		if node.Finally != nil {
+			ast.Walk(r, node.Finally)
+		}
+		return nil
+	}
+	return r
+}
+
+// identAt the identifier at p, or just before it so that the end of a name
+// being typed counts
+func (a *analysis) identAt(p pos) *ast.Identifier {
+	for _, ident := range a.idents {
+		start := tokenPos(ident.Token)
+		end := pos{start.line, start.column + len(ident.Value)}
+		if !p.before(start) && !end.before(p) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// visible the bindings in scope at p, by name, inner ones hiding outer ones
+func (a *analysis) visible(p pos) map[string]*binding {
+	names := map[string]*binding{}
+	for s := a.root.innermost(p); s != nil; s = s.parent {
+		for name := range s.names {
+			if _, ok := names[name]; !ok {
+				names[name] = s.lookup(name, p)
+			}
+		}
+	}
+	return names
+}
+
+// symbols the let bindings made in node, those in the functions they bind as
+// their children
+func (a *analysis) symbols(d *document, node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			name := d.identRange(node.Name)
+			sym := DocumentSymbol{
+				Name:           node.Name.Value,
+				Detail:         a.types[node.Name],
+				Kind:           SymbolVariable,
+				Range:          Range{Start: d.position(node.Token.Line, node.Token.Column), End: name.End},
+				SelectionRange: name,
+			}
+			if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+				sym.Kind = SymbolFunction
+				if end, ok := a.braces[tokenPos(fl.Body.Token)]; ok {
+					sym.Range.End = d.position(end.line, end.column)
+				}
+				sym.Children = a.symbols(d, fl.Body)
+			} else if node.Value != nil {
+				ast.Inspect(node.Value, func(node ast.Node) bool {
+					if fl, ok := node.(*ast.FunctionLiteral); ok {
+						sym.Children = append(sym.Children, a.symbols(d, fl.Body)...)
+						return false
+					}
+					return true
+				})
+			}
+			symbols = append(symbols, sym)
+			return false
+		}
+		return true
+	})
+	return symbols
+}
+
+// completions the keywords, builtins and names in scope at p
+func (a *analysis) completions(p pos) []CompletionItem {
+	items := []CompletionItem{}
+	for _, word := range token.Keywords() {
+		items = append(items, CompletionItem{Label: word, Kind: CompletionKeyword})
+	}
+
+	var names map[string]*binding
+	if a != nil {
+		names = a.visible(p)
+	} else {
+		names = map[string]*binding{}
+		for _, name := range evaluator.BuiltinNames() {
+			names[name] = &binding{kind: kindBuiltin}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		b := names[name]
+		item := CompletionItem{Label: name, Kind: CompletionVariable, Detail: b.kind}
+		switch {
+		case b.kind == kindBuiltin:
+			item.Kind = CompletionFunction
+			if doc, ok := builtinDocs[name]; ok {
+				item.Detail = doc.signature
+				item.Documentation = &MarkupContent{Kind: "markdown", Value: doc.doc}
+			}
+		case b.ident != nil:
+			if _, ok := b.value.(*ast.FunctionLiteral); ok {
+				item.Kind = CompletionFunction
+			}
+			if t, ok := a.types[b.ident]; ok {
+				item.Detail = b.kind + " " + name + ": " + t
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// hover describe the binding ident refers to: its kind and type, or the
+// documentation of a builtin
+func (a *analysis) hover(ident *ast.Identifier) string {
+	b := a.bindings[ident]
+	if b.kind == kindBuiltin {
+		doc := builtinDocs[ident.Value]
+		return "```monkey\n(builtin) " + doc.signature + "\n```\n\n" + doc.doc
+	}
+
+	line := "(" + b.kind + ") " + ident.Value
+	if t, ok := a.types[ident]; ok {
+		line += ": " + t
+	}
+	return "```monkey\n" + line + "\n```"
+}
+
+// position convert a line and byte column to an LSP position
+func (d *document) position(line, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(d.lines) {
+		return Position{Line: line - 1}
+	}
+
+	if column < 1 {
+		column = 1
+	}
+	text := d.lines[line-1]
+	if column-1 < len(text) {
+		text = text[:column-1]
+	}
+	return Position{Line: line - 1, Character: len(utf16.Encode([]rune(text)))}
+}
+
+// pos convert an LSP position to a line and byte column
+func (d *document) pos(p Position) pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return pos{p.Line + 1, p.Character + 1}
+	}
+
+	text, units, column := d.lines[p.Line], 0, 1
+	for _, r := range text {
+		if units >= p.Character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		column += utf8.RuneLen(r)
+	}
+	return pos{p.Line + 1, column}
+}
+
+// tokenRange the range of length bytes at line and column
+func (d *document) tokenRange(line, column, length int) Range {
+	return Range{Start: d.position(line, column), End: d.position(line, column+length)}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.tokenRange(ident.Token.Line, ident.Token.Column, len(ident.Value))
+}
+
+// wordRange the range of the word starting at line and column, or of the
+// character there if it does not start a word
+func (d *document) wordRange(line, column int) Range {
+	length := 1
+	if line >= 1 && line <= len(d.lines) && column >= 1 {
+		text := d.lines[line-1]
+		n := 0
+		for i := column - 1; i < len(text) && isWordByte(text[i]); i++ {
+			n++
+		}
+		if n != 0 {
+			length = n
+		}
+	}
+	return d.tokenRange(line, column, length)
+}
+
+func isWordByte(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_'
+}
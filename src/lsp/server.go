@@ -0,0 +1,305 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package lsp implements a Language Server Protocol server for monkey, for
+// editors to show diagnostics, hover information, definitions, document
+// symbols and completions as monkey files are edited.
+//
+// The server talks JSON-RPC over a stream, usually the standard input and
+// output of the monkey lsp command. Documents are synchronized in full on
+// every change. Diagnostics are parse errors and lint findings. Hover shows
+// the kind of binding a name refers to and the type the checker infers for
+// it, or the documentation of a builtin.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Server a language server reading requests from in and writing responses
+// to out
+type Server struct {
+	// Globals names defined by the host program, which are not reported as
+	// undefined
+	Globals []string
+
+	in  *bufio.Reader
+	out io.Writer
+
+	docs         map[string]*document
+	initialized  bool
+	shuttingDown bool
+}
+
+// errExitWithoutShutdown the client sent exit before shutdown
+var errExitWithoutShutdown = errors.New("exit without shutdown")
+
+// NewServer create a server talking over in and out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+// Run serve requests until the client sends exit. The error is nil if it
+// sent shutdown first, as it should
+func (s *Server) Run() error {
+	for {
+		msg, err := readMessage(s.in)
+		if err != nil {
+			if rerr, ok := err.(*responseError); ok {
+				// the message could be framed but not decoded, so it has no ID
+				if err := s.reply(nil, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shuttingDown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handler answer a request, or act on a notification, returning the result
+// of the request
+type handler func(s *Server, params json.RawMessage) (interface{}, *responseError)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":                  (*Server).initialize,
+		"initialized":                 (*Server).ignore,
+		"shutdown":                    (*Server).shutdown,
+		"textDocument/didOpen":        (*Server).didOpen,
+		"textDocument/didChange":      (*Server).didChange,
+		"textDocument/didClose":       (*Server).didClose,
+		"textDocument/didSave":        (*Server).ignore,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/completion":     (*Server).completion,
+	}
+}
+
+// handle dispatch msg to its handler, replying to requests
+func (s *Server) handle(msg *message) error {
+	isRequest := msg.ID != nil
+
+	h, ok := handlers[msg.Method]
+	switch {
+	case !ok:
+		if !isRequest {
+			// unknown notifications, such as $/cancelRequest, are dropped
+			return nil
+		}
+		return s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	case !s.initialized && msg.Method != "initialize":
+		if !isRequest {
+			return nil
+		}
+		return s.reply(msg.ID, nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"})
+	case s.shuttingDown:
+		if !isRequest {
+			return nil
+		}
+		return s.reply(msg.ID, nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	}
+
+	result, rerr := h(s, msg.Params)
+	if !isRequest {
+		return nil
+	}
+	return s.reply(msg.ID, result, rerr)
+}
+
+// reply answer the request id with result, or rerr if not nil
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	resp := &response{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		body, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = body
+	}
+	return writeMessage(s.out, resp)
+}
+
+// notify send the client a notification
+func (s *Server) notify(method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{JSONRPC: "2.0", Method: method, Params: body})
+}
+
+// decode params into v
+func decode(params json.RawMessage, v interface{}) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) ignore(params json.RawMessage) (interface{}, *responseError) {
+	return nil, nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, *responseError) {
+	s.initialized = true
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			// full document text on open and on every change
+			"textDocumentSync":       map[string]interface{}{"openClose": true, "change": 1},
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]interface{}{},
+		},
+		"serverInfo": map[string]interface{}{"name": "monkey"},
+	}, nil
+}
+
+func (s *Server) shutdown(params json.RawMessage) (interface{}, *responseError) {
+	s.shuttingDown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, *responseError) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d := &document{uri: p.TextDocument.URI}
+	s.docs[d.uri] = d
+	s.update(d, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, *responseError) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok || len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	// changes are whole documents, the last one is the current text
+	s.update(d, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil, nil
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, *responseError) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	delete(s.docs, p.TextDocument.URI)
+	// clear the diagnostics of the closed document
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil, nil
+}
+
+// update analyse the new text of d and publish its diagnostics
+func (s *Server) update(d *document, text string) {
+	d.update(text, s.Globals)
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: d.uri, Diagnostics: d.diagnostics})
+}
+
+// positionDocument decode the params of a request about a position, and
+// find the document it is in
+func (s *Server) positionDocument(params json.RawMessage) (*document, *positionParams, *responseError) {
+	p := &positionParams{}
+	if err := decode(params, p); err != nil {
+		return nil, nil, err
+	}
+
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil, &responseError{Code: codeInvalidParams, Message: "unknown document " + p.TextDocument.URI}
+	}
+	return d, p, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, *responseError) {
+	d, p, err := s.positionDocument(params)
+	if err != nil {
+		return nil, err
+	}
+	if d.analysis == nil {
+		return nil, nil
+	}
+
+	ident := d.analysis.identAt(d.pos(p.Position))
+	if ident == nil {
+		return nil, nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: d.analysis.hover(ident)},
+		Range:    d.identRange(ident),
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, *responseError) {
+	d, p, err := s.positionDocument(params)
+	if err != nil {
+		return nil, err
+	}
+	if d.analysis == nil {
+		return nil, nil
+	}
+
+	ident := d.analysis.identAt(d.pos(p.Position))
+	if ident == nil {
+		return nil, nil
+	}
+	b := d.analysis.bindings[ident]
+	if b.ident == nil {
+		// builtins and globals are defined nowhere in the document
+		return nil, nil
+	}
+	return []Location{{URI: d.uri, Range: d.identRange(b.ident)}}, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, *responseError) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown document " + p.TextDocument.URI}
+	}
+	if d.analysis == nil {
+		return []DocumentSymbol{}, nil
+	}
+	return d.analysis.symbols(d, d.analysis.program), nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, *responseError) {
+	d, p, err := s.positionDocument(params)
+	if err != nil {
+		return nil, err
+	}
+	return d.analysis.completions(d.pos(p.Position)), nil
+}
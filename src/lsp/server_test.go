@@ -0,0 +1,353 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"evaluator"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.mk"
+
+// session run a server on requests, a list of method and params pairs, and
+// return the responses by request index and the diagnostics last published.
+// Every request gets its index as ID, initialize is sent first and shutdown
+// and exit last
+func session(t *testing.T, requests ...interface{}) (map[int]json.RawMessage, []Diagnostic) {
+	t.Helper()
+
+	var in bytes.Buffer
+	send := func(id int, method string, params interface{}) {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+		if id >= 0 {
+			msg["id"] = id
+		}
+		body, _ := json.Marshal(msg)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	send(1000, "initialize", map[string]interface{}{})
+	send(-1, "initialized", map[string]interface{}{})
+	for i := 0; i < len(requests); i += 2 {
+		method := requests[i].(string)
+		id := i / 2
+		if strings.HasPrefix(method, "textDocument/did") {
+			id = -1
+		}
+		send(id, method, requests[i+1])
+	}
+	send(1001, "shutdown", nil)
+	send(-1, "exit", nil)
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	results := map[int]json.RawMessage{}
+	var diagnostics []Diagnostic
+	r := bufio.NewReader(&out)
+	for {
+		var msg struct {
+			ID     *int
+			Method string
+			Params json.RawMessage
+			Result json.RawMessage
+			Error  *responseError
+		}
+		header, err := readHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading response: %s", err)
+		}
+		body := make([]byte, header)
+		io.ReadFull(r, body)
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("decoding %s: %s", body, err)
+		}
+
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			var p publishDiagnosticsParams
+			json.Unmarshal(msg.Params, &p)
+			diagnostics = p.Diagnostics
+		case msg.Error != nil:
+			t.Fatalf("request %d failed: %s", *msg.ID, msg.Error.Message)
+		case msg.ID != nil:
+			results[*msg.ID] = msg.Result
+		}
+	}
+	return results, diagnostics
+}
+
+func readHeader(r *bufio.Reader) (int, error) {
+	length := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return length, nil
+		}
+		fmt.Sscanf(line, "Content-Length: %d", &length)
+	}
+}
+
+func open(text string) []interface{} {
+	return []interface{}{"textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI, "languageId": "monkey", "version": 1, "text": text},
+	}}
+}
+
+func at(method string, line, character int) []interface{} {
+	return []interface{}{method, map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}}
+}
+
+func join(requests ...[]interface{}) []interface{} {
+	var all []interface{}
+	for _, r := range requests {
+		all = append(all, r...)
+	}
+	return all
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"let = 1;", []string{
+			"0:4-0:5 error: expected next token to be IDENT, got = instead",
+			"0:4-0:5 error: no prefix parse function for = found",
+		}},
+		{"let x = 1;\nlet f = fn(a) { 1 };\nputs(y)", []string{
+			"1:11-1:12 warning: parameter a is never used (unused-parameter)",
+			"2:5-2:6 warning: y is not defined (undefined)",
+		}},
+		{"#!monkey\n\"é\" + y", []string{"1:6-1:7 warning: y is not defined (undefined)"}},
+	}
+
+	for _, tt := range tests {
+		_, diagnostics := session(t, open(tt.input)...)
+
+		var got []string
+		for _, d := range diagnostics {
+			severity := "error"
+			if d.Severity == SeverityWarning {
+				severity = "warning"
+			}
+			s := fmt.Sprintf("%d:%d-%d:%d %s: %s", d.Range.Start.Line, d.Range.Start.Character,
+				d.Range.End.Line, d.Range.End.Character, severity, d.Message)
+			if d.Code != "" {
+				s += " (" + d.Code + ")"
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("diagnostics of %q wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+const program = `let add = fn(a, b) { a + b };
+let total = add(1, 2);
+let f = fn(n) {
+  let twice = n * 2;
+  try { twice } catch (e) { e }
+};
+len("abc") + total
+`
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 5, "```monkey\n(let) add: fn(a, a) -> a\n```"},
+		{0, 13, "```monkey\n(parameter) a: a\n```"},
+		{1, 13, "```monkey\n(let) add: fn(int, int) -> int\n```"},
+		{3, 14, "```monkey\n(parameter) n: int\n```"},
+		{4, 28, "```monkey\n(catch parameter) e: any\n```"},
+		{6, 1, "```monkey\n(builtin) len(value)\n```\n\nNumber of characters of a string or elements of an array."},
+		// the end of a name is still in it
+		{6, 18, "```monkey\n(let) total: int\n```"},
+		{6, 5, ""},
+	}
+
+	for _, tt := range tests {
+		results, _ := session(t, join(open(program), at("textDocument/hover", tt.line, tt.character))...)
+
+		var hover *Hover
+		json.Unmarshal(results[1], &hover)
+		got := ""
+		if hover != nil {
+			got = hover.Contents.Value
+		}
+		if got != tt.expected {
+			t.Errorf("hover at %d:%d wrong. expected=%q, got=%q", tt.line, tt.character, tt.expected, got)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 21, "0:13"},
+		{1, 13, "0:4"},
+		{4, 9, "3:6"},
+		{4, 28, "4:23"},
+		{6, 15, "1:4"},
+		{6, 1, ""},
+	}
+
+	for _, tt := range tests {
+		results, _ := session(t, join(open(program), at("textDocument/definition", tt.line, tt.character))...)
+
+		var locations []Location
+		json.Unmarshal(results[1], &locations)
+		got := ""
+		if len(locations) == 1 {
+			got = fmt.Sprintf("%d:%d", locations[0].Range.Start.Line, locations[0].Range.Start.Character)
+		}
+		if got != tt.expected {
+			t.Errorf("definition at %d:%d wrong. expected=%q, got=%q", tt.line, tt.character, tt.expected, got)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	results, _ := session(t, join(open(program), []interface{}{"textDocument/documentSymbol", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI},
+	}})...)
+
+	var symbols []DocumentSymbol
+	json.Unmarshal(results[1], &symbols)
+
+	var describe func(symbols []DocumentSymbol) string
+	describe = func(symbols []DocumentSymbol) string {
+		var out []string
+		for _, s := range symbols {
+			desc := fmt.Sprintf("%s %d %d:%d-%d:%d", s.Name, s.Kind, s.Range.Start.Line, s.Range.Start.Character,
+				s.Range.End.Line, s.Range.End.Character)
+			if len(s.Children) != 0 {
+				desc += " [" + describe(s.Children) + "]"
+			}
+			out = append(out, desc)
+		}
+		return strings.Join(out, ", ")
+	}
+
+	expected := "add 12 0:0-0:28, total 13 1:0-1:9, f 12 2:0-5:1 [twice 13 3:2-3:11]"
+	if got := describe(symbols); got != expected {
+		t.Errorf("symbols wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line, character int
+		included        []string
+		excluded        []string
+	}{
+		{6, 0, []string{"add", "total", "f", "len", "let", "fn"}, []string{"a", "n", "twice", "e"}},
+		{0, 22, []string{"a", "b", "add", "puts"}, []string{"n"}},
+		{4, 28, []string{"e", "twice", "n", "f"}, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		results, _ := session(t, join(open(program), at("textDocument/completion", tt.line, tt.character))...)
+
+		var items []CompletionItem
+		json.Unmarshal(results[1], &items)
+		labels := map[string]bool{}
+		for _, item := range items {
+			labels[item.Label] = true
+		}
+
+		for _, name := range tt.included {
+			if !labels[name] {
+				t.Errorf("completion at %d:%d misses %s", tt.line, tt.character, name)
+			}
+		}
+		for _, name := range tt.excluded {
+			if labels[name] {
+				t.Errorf("completion at %d:%d has %s", tt.line, tt.character, name)
+			}
+		}
+	}
+}
+
+func TestStaleAnalysis(t *testing.T) {
+	change := []interface{}{"textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": testURI, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": "let total = 1;\ntotal + (\n"}},
+	}}
+	results, diagnostics := session(t, join(open("let total = 1;\ntotal\n"), change, at("textDocument/hover", 1, 2))...)
+
+	if len(diagnostics) == 0 || diagnostics[0].Severity != SeverityError {
+		t.Errorf("expected a parse error, got=%v", diagnostics)
+	}
+
+	var hover *Hover
+	json.Unmarshal(results[2], &hover)
+	if hover == nil || !strings.Contains(hover.Contents.Value, "(let) total: int") {
+		t.Errorf("hover of the last parsed version wrong. got=%s", results[2])
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	var in bytes.Buffer
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != errExitWithoutShutdown {
+		t.Errorf("Run error wrong. expected=%v, got=%v", errExitWithoutShutdown, err)
+	}
+
+	for _, expected := range []string{
+		`"id":1,"error":{"code":-32002`,
+		`"id":2,"result":{"capabilities"`,
+		`"id":3,"error":{"code":-32601`,
+		`"id":null,"error":{"code":-32700`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %s. got=%s", expected, out.String())
+		}
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	for _, name := range evaluator.BuiltinNames() {
+		if _, ok := builtinDocs[name]; !ok {
+			t.Errorf("builtin %s has no documentation", name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunLSP(t *testing.T) {
+	frame := func(bodies ...string) string {
+		var out strings.Builder
+		for _, body := range bodies {
+			fmt.Fprintf(&out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+		}
+		return out.String()
+	}
+
+	usage := lspUsage + "  -globals names\n    \tcomma separated names defined by the host program\n"
+
+	// the responses are framed JSON whose other members do not matter, so
+	// stdout only has to contain the expected text, and be empty when it is
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"lsp"}, frame(
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+			`{"jsonrpc":"2.0","method":"exit"}`,
+		), 0, `"hoverProvider":true`, ""},
+		{[]string{"lsp", "-globals", "host"}, frame(
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+			`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.mk","text":"host(1)"}}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+			`{"jsonrpc":"2.0","method":"exit"}`,
+		), 0, `"diagnostics":[]`, ""},
+		{[]string{"lsp"}, frame(`{"jsonrpc":"2.0","method":"exit"}`), 1, "", "monkey lsp: exit without shutdown\n"},
+		{[]string{"lsp"}, "", 1, "", "monkey lsp: EOF\n"},
+		{[]string{"lsp", "file.mk"}, "", exitUsage, "", usage},
+		{[]string{"lsp", "-bogus"}, "", exitUsage, "", "flag provided but not defined: -bogus\n" + usage},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+		if tt.expectedStdout == "" && stdout.Len() != 0 {
+			t.Errorf("run(%q) wrote to stdout: %q", tt.args, stdout.String())
+		}
+		if !strings.Contains(stdout.String(), tt.expectedStdout) {
+			t.Errorf("run(%q) stdout wrong. expected to contain %q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("run(%q) stderr wrong. expected=%q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
@@ -6,18 +6,171 @@
 package main
 
 import (
+	"evaluator"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"lexer"
+	"object"
 	"os"
 	"os/user"
+	"parser"
+	"path/filepath"
 	"repl"
 )
 
+const (
+	// exitRuntimeError script raised an uncaught runtime error
+	exitRuntimeError = 1
+	// exitUsage bad command line, unreadable or unparsable script
+	exitUsage = 2
+)
+
+const usage = `usage: monkey [-O] [-e expr] [file | -]
+       monkey fmt [-check | -w] [file ...]
+       monkey parse [-json] [file | -]
+       monkey lint [-disable rules] [-globals names] [file ...]
+       monkey check [-globals names] [-types] [file ...]
+       monkey lsp [-globals names]
+       monkey debug [-dap] [file]
+
+Run file, or standard input when file is -, as a monkey script. With -e,
+evaluate expr and print its value. With neither, start the interactive REPL.
+With -O, constant expressions are folded and dead branches removed first.
+The fmt command formats source files, parse prints the syntax tree of one,
+lint reports likely mistakes, check reports type errors, lsp serves editors
+and debug runs a script under a debugger, see monkey <command> -h.
+
+Imports are resolved next to the importing file, then in each directory of
+the MONKEYPATH environment variable.
+
+flags:
+`
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 0 {
+		switch args[0] {
+		case "fmt":
+			return runFmt(args[1:], stdin, stdout, stderr)
+		case "parse":
+			return runParse(args[1:], stdin, stdout, stderr)
+		case "lint":
+			return runLint(args[1:], stdin, stdout, stderr)
+		case "check":
+			return runCheck(args[1:], stdin, stdout, stderr)
+		case "lsp":
+			return runLSP(args[1:], stdin, stdout, stderr)
+		case "debug":
+			return runDebug(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, usage)
+		flags.PrintDefaults()
+	}
+	expr := flags.String("e", "", "evaluate `expr` and print its value")
+	optimize := flags.Bool("O", false, "optimize programs before evaluating them")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	ev := evaluator.New()
+	ev.SearchPath = filepath.SplitList(os.Getenv("MONKEYPATH"))
+	ev.Optimize = *optimize
+	ev.MaxDepth = evaluator.DefaultMaxDepth
+
+	switch {
+	case *expr != "":
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return exitUsage
+		}
+		return runSource(ev, "<expr>", *expr, true, stdout, stderr)
+	case flags.NArg() == 0:
+		return startRepl(ev, stdin, stdout, stderr)
+	case flags.NArg() == 1:
+		return runFile(ev, flags.Arg(0), stdin, stdout, stderr)
+	default:
+		flags.Usage()
+		return exitUsage
+	}
+}
+
+func startRepl(ev *evaluator.Evaluator, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	s := repl.NewSession(stdin, stdout, stderr)
+	s.Evaluator = ev
+
+	name := "there"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	s.Banner = fmt.Sprintf("Hello %s! This is the Monkey programming language!\nFeel free to type in commands\n", name)
+
+	if err := s.Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey: %s\n", err)
+		return exitUsage
+	}
+	return 0
+}
+
+func runFile(ev *evaluator.Evaluator, path string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	var src []byte
+	var err error
+
+	name := path
+	if path == "-" {
+		name = "<stdin>"
+		src, err = ioutil.ReadAll(stdin)
+	} else {
+		src, err = ioutil.ReadFile(path)
+		ev.File = path
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(stderr, "monkey: %s\n", err)
+		return exitUsage
+	}
+
+	return runSource(ev, name, lexer.SkipShebang(string(src)), false, stdout, stderr)
+}
+
+// runSource evaluate src, reporting errors on stderr, parse errors at their
+// position in name. If print is set, the resulting value is written to stdout
+func runSource(ev *evaluator.Evaluator, name string, src string, print bool, stdout io.Writer, stderr io.Writer) int {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(stderr, name, p)
+		return exitUsage
+	}
+
+	evaluated := ev.Eval(program, object.NewEnvironment())
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(stderr, err.Traceback())
+		io.WriteString(stderr, "\n")
+		return exitRuntimeError
+	}
+
+	if print && evaluated != nil && evaluated != evaluator.NULL {
+		io.WriteString(stdout, evaluated.Inspect())
+		io.WriteString(stdout, "\n")
+	}
+
+	return 0
+}
+
+// printParseErrors write the errors of p to w, at their position in name
+func printParseErrors(w io.Writer, name string, p *parser.Parser) {
+	for i, msg := range p.Errors() {
+		tok := p.ErrorTokens()[i]
+		fmt.Fprintf(w, "%s:%d:%d: parse error: %s\n", name, tok.Line, tok.Column, msg)
 	}
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
 }
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	src := "#!/usr/bin/env monkey\nlet f = fn() { 1 + true };\nf()\n"
+	if err := ioutil.WriteFile(script, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"-e", "1 + 2"}, "", 0, "3\n", ""},
+		{[]string{"-e", "let x = 1;"}, "", 0, "", ""},
+		{[]string{"-e", "let"}, "", exitUsage, "", "<expr>:1:4: parse error: expected next token to be IDENT, got EOF instead\n"},
+		{[]string{"-e", "1 + true"}, "", exitRuntimeError, "", "type mismatch: INTEGER + BOOLEAN"},
+		{[]string{"-O", "-e", "let day = 60 * 60 * 24; day * 7"}, "", 0, "604800\n", ""},
+		{[]string{"-O", "-e", "let f = fn() { 1 / 0 }; f()"}, "", exitRuntimeError, "", "line 1, column 18, in f\nERROR: division by zero"},
+		{[]string{"-"}, "#!monkey\n1 +", exitUsage, "", "<stdin>:2:4: parse error: no prefix parse function for EOF found\n"},
+		{[]string{"-"}, "let x = 1;", 0, "", ""},
+		{[]string{script}, "", exitRuntimeError, "", "  " + script + ":2:18, in f\nERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"-e", "let f = fn(n) { 1 + f(n + 1) }; f(0)"}, "", exitRuntimeError, "",
+			"  line 1, column 22, in f\n  [previous line repeated 9999 more times]\nERROR: call depth limit of 10000 exceeded\n"},
+		{[]string{"-e", "let f = fn() { x }; 1"}, "", exitRuntimeError, "", "line 1, column 16, in <main>\nERROR: identifier not found: x\n"},
+		{[]string{filepath.Join(dir, "missing.mk")}, "", exitUsage, "", "no such file or directory"},
+		{[]string{"a.mk", "b.mk"}, "", exitUsage, "", "usage: monkey"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("run(%q) stdout wrong. expected=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("run(%q) stderr wrong. expected to contain %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package monkey
+
+import (
+	"evaluator"
+	"fmt"
+	"object"
+	"reflect"
+	"strings"
+)
+
+// Conversion between Go values and monkey objects:
+//
+//	Go                                   monkey
+//	int*, uint*                          INTEGER
+//	float32, float64                     FLOAT
+//	string                               STRING
+//	bool                                 BOOLEAN
+//	slice, array                         ARRAY
+//	map with number, string, bool keys   HASH
+//	struct                               HASH keyed by field name
+//	nil pointer, slice, map              NULL
+//	object.Object                        itself
+//
+// Struct fields are named by their `monkey:"name"` tag, or by the field name
+// when untagged. Fields tagged `monkey:"-"` and unexported fields are skipped.
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject convert a Go value into a monkey object
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return evaluator.NULL, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if v.Type().Implements(objectType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object.NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if int64(u) < 0 {
+			return nil, fmt.Errorf("monkey: %d overflows INTEGER", u)
+		}
+		return object.NewInteger(int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		pairs := make(map[object.HashKey]object.HashPair)
+		for _, k := range v.MapKeys() {
+			key, err := toObject(k)
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("monkey: unusable as hash key: %s", k.Type())
+			}
+			value, err := toObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case reflect.Struct:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for _, f := range structFields(v.Type()) {
+			value, err := toObject(v.FieldByIndex(f.index))
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: f.name}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	}
+
+	return nil, fmt.Errorf("monkey: cannot convert %s to an object", v.Type())
+}
+
+// FromObject stores obj in the Go value target points to, converting it as
+// described for ToObject. Stored into an interface{}, INTEGER becomes int64
+// and FLOAT becomes float64. ARRAY becomes []interface{}. HASH becomes
+// map[string]interface{} when all its keys are strings, and
+// map[interface{}]interface{} otherwise.
+func FromObject(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("monkey: FromObject target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, v.Elem())
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	// object.Object and concrete object types take the object itself
+	if obj != nil && (v.Kind() != reflect.Interface || v.Type() == objectType) {
+		if ov := reflect.ValueOf(obj); ov.Type().AssignableTo(v.Type()) {
+			v.Set(ov)
+			return nil
+		}
+	}
+
+	if obj == evaluator.NULL || obj == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("monkey: cannot convert %s to %s", obj.Type(), v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(i.Value()) {
+			return fmt.Errorf("monkey: %d overflows %s", i.Value(), v.Type())
+		}
+		v.SetInt(i.Value())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if i.Value() < 0 || v.OverflowUint(uint64(i.Value())) {
+			return fmt.Errorf("monkey: %d overflows %s", i.Value(), v.Type())
+		}
+		v.SetUint(uint64(i.Value()))
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value()))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s.Value)
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := fromObject(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice, reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() == reflect.Array {
+			if len(arr.Elements) != v.Len() {
+				return fmt.Errorf("monkey: cannot convert ARRAY of length %d to %s", len(arr.Elements), v.Type())
+			}
+		} else {
+			v.Set(reflect.MakeSlice(v.Type(), len(arr.Elements), len(arr.Elements)))
+		}
+		for i, el := range arr.Elements {
+			if err := fromObject(el, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := fromObject(pair.Value, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range structFields(v.Type()) {
+			pair, ok := hash.Pairs[(&object.String{Value: f.name}).HashKey()]
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, v.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("%s (field %s)", err, f.name)
+			}
+		}
+	case reflect.Interface:
+		natural, err := naturalValue(obj)
+		if err != nil {
+			return err
+		}
+		if natural == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		nv := reflect.ValueOf(natural)
+		if !nv.Type().AssignableTo(v.Type()) {
+			return mismatch()
+		}
+		v.Set(nv)
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+// naturalValue the Go value an object converts to when the target type does
+// not say
+func naturalValue(obj object.Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value(), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		var values []interface{}
+		if err := fromObject(obj, reflect.ValueOf(&values).Elem()); err != nil {
+			return nil, err
+		}
+		return values, nil
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != object.STRINGOBJ {
+				var values map[interface{}]interface{}
+				err := fromObject(obj, reflect.ValueOf(&values).Elem())
+				return values, err
+			}
+		}
+		var values map[string]interface{}
+		err := fromObject(obj, reflect.ValueOf(&values).Elem())
+		return values, err
+	}
+	return obj, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("monkey"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag = strings.Split(tag, ",")[0]; tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+
+	return fields
+}
+
+// wrapFunc adapt an ordinary Go function to a builtin. Arguments are converted
+// with FromObject; the results, an optional value followed by an optional
+// error, with ToObject. A non-nil error raises a monkey error
+func wrapFunc(name string, fn interface{}) (object.BuiltinFunction, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("monkey: cannot register %s, %T is not a function", name, fn)
+	}
+
+	switch {
+	case ft.NumOut() > 2,
+		ft.NumOut() == 2 && ft.Out(1) != errorType:
+		return nil, fmt.Errorf("monkey: cannot register %s, results must be (value), (error) or (value, error)", name)
+	}
+
+	return func(args ...object.Object) object.Object {
+		fixed := ft.NumIn()
+		if ft.IsVariadic() {
+			fixed--
+			if len(args) < fixed {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), fixed)}
+			}
+		} else if len(args) != fixed {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), fixed)}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var t reflect.Type
+			if i < fixed {
+				t = ft.In(i)
+			} else {
+				t = ft.In(fixed).Elem()
+			}
+			v := reflect.New(t).Elem()
+			if err := fromObject(arg, v); err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, strings.TrimPrefix(err.Error(), "monkey: "))}
+			}
+			in[i] = v
+		}
+
+		out := fv.Call(in)
+
+		if n := len(out); n > 0 && ft.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+
+		obj, err := toObject(out[0])
+		if err != nil {
+			return &object.Error{Message: fmt.Sprintf("result of `%s`: %s", name, strings.TrimPrefix(err.Error(), "monkey: "))}
+		}
+		return obj
+	}, nil
+}
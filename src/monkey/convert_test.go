@@ -0,0 +1,207 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package monkey
+
+import (
+	"errors"
+	"object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X      int    `monkey:"x"`
+	Y      int    `monkey:"y"`
+	Label  string `monkey:"-"`
+	Weight uint8
+	hidden bool
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint16(7), "7"},
+		{"hi", "hi"},
+		{2.5, "2.5"},
+		{true, "true"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]interface{}{1, "a", nil}, "[1, a, null]"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{point{X: 1, Label: "skip", hidden: true}, ""},
+		{object.NewInteger(3), "3"},
+		{(*int)(nil), "null"},
+		{[]int(nil), "null"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned error %v", tt.input, err)
+			continue
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. expected=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, _ := ToObject(point{X: 1, Y: 2, Label: "skip", Weight: 3})
+	hash := obj.(*object.Hash)
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("struct hash has wrong num of pairs. got=%d (%s)", len(hash.Pairs), hash.Inspect())
+	}
+	for key, expected := range map[string]int64{"x": 1, "y": 2, "Weight": 3} {
+		pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+		if !ok || pair.Value.(*object.Integer).Value() != expected {
+			t.Errorf("struct field %s wrong. got=%+v", key, pair.Value)
+		}
+	}
+
+	if _, err := ToObject(complex(1, 2)); err == nil {
+		t.Errorf("expected error converting complex128")
+	}
+	if _, err := ToObject(map[[1]int]int{{1}: 1}); err == nil {
+		t.Errorf("expected error converting array keys")
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	obj, err := New().Run(`{"x": 1, "y": -2, "Weight": 3, "tags": ["a", "b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p point
+	if err := FromObject(obj, &p); err != nil {
+		t.Fatalf("FromObject returned error %v", err)
+	}
+	if p != (point{X: 1, Y: -2, Weight: 3}) {
+		t.Errorf("struct wrong. got=%+v", p)
+	}
+
+	var m map[string]interface{}
+	if err := FromObject(obj, &m); err != nil {
+		t.Fatalf("FromObject returned error %v", err)
+	}
+	expected := map[string]interface{}{"x": int64(1), "y": int64(-2), "Weight": int64(3), "tags": []interface{}{"a", "b"}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("map wrong. expected=%#v, got=%#v", expected, m)
+	}
+
+	var any interface{}
+	intKeys, _ := New().Run(`{1: true, 2: false}`)
+	if err := FromObject(intKeys, &any); err != nil {
+		t.Fatalf("FromObject returned error %v", err)
+	}
+	if !reflect.DeepEqual(any, map[interface{}]interface{}{int64(1): true, int64(2): false}) {
+		t.Errorf("interface wrong. got=%#v", any)
+	}
+
+	var f float64
+	if err := FromObject(object.NewInteger(2), &f); err != nil || f != 2 {
+		t.Errorf("float wrong. got=%v, %v", f, err)
+	}
+
+	var ptr *int
+	if err := FromObject(object.NewInteger(5), &ptr); err != nil || *ptr != 5 {
+		t.Errorf("pointer wrong. got=%v, %v", ptr, err)
+	}
+
+	var hash *object.Hash
+	if err := FromObject(obj, &hash); err != nil || hash != obj {
+		t.Errorf("object target wrong. got=%v, %v", hash, err)
+	}
+
+	errorTests := []struct {
+		obj    object.Object
+		target interface{}
+		msg    string
+	}{
+		{&object.String{Value: "a"}, new(int), "cannot convert STRING to int"},
+		{object.NewInteger(300), new(uint8), "300 overflows uint8"},
+		{object.NewInteger(-1), new(uint), "-1 overflows uint"},
+		{obj, new([]int), "cannot convert HASH to []int"},
+		{obj, 5, "target must be a non-nil pointer"},
+	}
+	for _, tt := range errorTests {
+		err := FromObject(tt.obj, tt.target)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("FromObject(%s, %T) wrong error. expected %q, got=%v", tt.obj.Inspect(), tt.target, tt.msg, err)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	in := New()
+
+	registrations := map[string]interface{}{
+		"repeat": strings.Repeat,
+		"sum": func(base int, xs ...int) int {
+			for _, x := range xs {
+				base += x
+			}
+			return base
+		},
+		"check": func(ok bool) error {
+			if !ok {
+				return errors.New("check failed")
+			}
+			return nil
+		},
+		"norm": func(p point) (point, error) {
+			if p.X < 0 {
+				return point{}, errors.New("negative x")
+			}
+			return point{X: p.X, Y: p.X}, nil
+		},
+		"raw": func(args ...object.Object) object.Object { return object.NewInteger(int64(len(args))) },
+	}
+	for name, fn := range registrations {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatalf("Register(%s) returned error %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum(1)`, "1"},
+		{`sum(1, 2, 3)`, "6"},
+		{`check(true)`, "null"},
+		{`norm({"x": 2}).y`, "2"},
+		{`raw(1, 2, 3)`, "3"},
+		{`try { check(false) } catch (e) { e["message"] }`, "check failed"},
+		{`try { norm({"x": -1}) } catch (e) { e["message"] }`, "negative x"},
+		{`try { repeat("a") } catch (e) { e["message"] }`, "wrong number of arguments. got=1, want=2"},
+		{`try { sum() } catch (e) { e["message"] }`, "wrong number of arguments. got=0, want at least 1"},
+		{`try { repeat(1, 2) } catch (e) { e["message"] }`, "argument 1 to `repeat`: cannot convert INTEGER to string"},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Run(tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Run(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err := in.Register("bad", 5); err == nil {
+		t.Errorf("expected error registering a non-function")
+	}
+	if err := in.Register("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error registering a function with two values")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package monkey embeds the monkey interpreter in Go programs.
+//
+// An Interpreter keeps its globals between runs:
+//
+//	in := monkey.New()
+//	in.Register("shout", strings.ToUpper)
+//	if _, err := in.Run(`let greet = fn(name) { shout("hi " + name) };`); err != nil {
+//		...
+//	}
+//	result, err := in.Call("greet", &object.String{Value: "gopher"})
+//
+// To run untrusted code, bound its run time with SetLimits and the context
+// passed to RunContext or CallContext, and its allocations with
+// SetMemoryLimit. A RuntimeError whose Err is Aborted
+// reports that a limit was hit or the context was done.
+//
+// Interpreters share no state, each may be used by its own goroutine. A
+// single Interpreter must not be used concurrently.
+package monkey
+
+import (
+	"context"
+	"evaluator"
+	"fmt"
+	"io/ioutil"
+	"lexer"
+	"object"
+	"parser"
+	"strings"
+)
+
+// ParseError source could not be parsed
+type ParseError struct {
+	// File is the path of the source, empty for Run
+	File   string
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	prefix := "parse error"
+	if e.File != "" {
+		prefix = e.File + ": " + prefix
+	}
+	return prefix + ": " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError evaluation raised an uncaught monkey error
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Line > 0 {
+		return object.Position(e.Err.File, e.Err.Line, e.Err.Column) + ": " + e.Err.Message
+	}
+	return e.Err.Message
+}
+
+// Traceback render the error with the chain of calls that led to it
+func (e *RuntimeError) Traceback() string {
+	return e.Err.Traceback()
+}
+
+// Interpreter monkey interpreter with its own globals and imported modules
+type Interpreter struct {
+	env *object.Environment
+	ev  *evaluator.Evaluator
+}
+
+// New create new Interpreter instance, its call depth limited to
+// evaluator.DefaultMaxDepth
+func New() *Interpreter {
+	ev := evaluator.New()
+	ev.MaxDepth = evaluator.DefaultMaxDepth
+	return &Interpreter{env: object.NewEnvironment(), ev: ev}
+}
+
+// SetSearchPath set the directories searched for imports
+func (i *Interpreter) SetSearchPath(dirs ...string) {
+	i.ev.SearchPath = dirs
+}
+
+// SetLimits stop runs after maxSteps evaluation steps or when calls nest
+// deeper than maxDepth, 0 meaning no limit. A depth of 0 lets deep recursion
+// overflow the Go stack
+func (i *Interpreter) SetLimits(maxSteps, maxDepth int) {
+	i.ev.MaxSteps = maxSteps
+	i.ev.MaxDepth = maxDepth
+}
+
+// SetMemoryLimit raise a catchable error when a run allocates more than about
+// that many bytes for strings, arrays and hashes, 0 meaning no limit
+func (i *Interpreter) SetMemoryLimit(bytes int64) {
+	i.ev.MaxMemory = bytes
+}
+
+// Run evaluate src in the global environment, returning the value of its
+// last statement
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.run(context.Background(), "", src)
+}
+
+// RunContext evaluate src like Run, stopping when ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	return i.run(ctx, "", src)
+}
+
+// RunFile evaluate the file at path in the global environment. Imports in the
+// file are resolved relative to it
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := i.ev.File
+	i.ev.File = path
+	defer func() { i.ev.File = file }()
+
+	return i.run(context.Background(), path, string(src))
+}
+
+func (i *Interpreter) run(ctx context.Context, file string, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{File: file, Errors: p.Errors()}
+	}
+
+	return result(i.ev.EvalContext(ctx, program, i.env))
+}
+
+// Call call the global function name with args
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext call the global function name like Call, stopping when ctx is
+// done
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("monkey: no global named %s", name)
+	}
+
+	return result(i.ev.ApplyContext(ctx, fn, args...))
+}
+
+// Set bind val to the global name
+func (i *Interpreter) Set(name string, val object.Object) {
+	i.env.Set(name, val)
+}
+
+// Get look up the global name
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Register bind fn as the global builtin function name. A function of type
+// object.BuiltinFunction receives and returns monkey objects as they are; an
+// error object it returns raises a monkey error that scripts can catch. Any
+// other function has its arguments and results converted as described in
+// ToObject and FromObject, and may return an error as its last result
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	var builtin object.BuiltinFunction
+
+	switch fn := fn.(type) {
+	case object.BuiltinFunction:
+		builtin = fn
+	case func(args ...object.Object) object.Object:
+		builtin = fn
+	default:
+		wrapped, err := wrapFunc(name, fn)
+		if err != nil {
+			return err
+		}
+		builtin = wrapped
+	}
+
+	i.env.Set(name, &object.Builtin{Fn: builtin})
+	return nil
+}
+
+// result convert an evaluation result into the Go convention
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package monkey
+
+import (
+	"context"
+	"errors"
+	"evaluator"
+	"io/ioutil"
+	"object"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	in := New()
+
+	if _, err := in.Run("let double = fn(x) { x * 2 };"); err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+
+	result, err := in.Run("double(21)")
+	if err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+	testInteger(t, result, 42)
+
+	result, err = in.Run("let unused = 1;")
+	if err != nil || result.Type() != object.NULLOBJ {
+		t.Errorf("Run of let statement wrong. got=%v, %v", result, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Run("let = 5;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+	if len(parseErr.Errors) == 0 {
+		t.Errorf("ParseError has no messages")
+	}
+
+	_, err = in.Run("let f = fn() { 1 + true };\nf()")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if err.Error() != "line 1, column 18: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+	if len(runtimeErr.Err.Stack) != 1 || runtimeErr.Err.Stack[0].Function != "f" {
+		t.Errorf("wrong stack. got=%+v", runtimeErr.Err.Stack)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New()
+	in.SetLimits(10000, 0)
+
+	_, err := in.RunContext(context.Background(), "let spin = fn(n) { spin(n + 1) };\nspin(0)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || !runtimeErr.Err.Aborted() || runtimeErr.Err.Kind != object.StepLimit {
+		t.Fatalf("expected step limit error. got=%T (%v)", err, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = in.CallContext(ctx, "spin", object.NewInteger(0))
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.Canceled {
+		t.Fatalf("expected canceled error. got=%T (%v)", err, err)
+	}
+
+	if _, err := in.Run("1 + 1"); err != nil {
+		t.Errorf("Run after a limit was hit returned error %v", err)
+	}
+}
+
+func TestDefaultDepthLimit(t *testing.T) {
+	_, err := New().Run("let f = fn(n) { 1 + f(n + 1) };\nf(0)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.DepthLimit {
+		t.Fatalf("expected depth limit error. got=%T (%v)", err, err)
+	}
+	if len(runtimeErr.Err.Stack) != evaluator.DefaultMaxDepth {
+		t.Errorf("wrong stack depth. expected=%d, got=%d", evaluator.DefaultMaxDepth, len(runtimeErr.Err.Stack))
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	in := New()
+	in.SetMemoryLimit(1 << 16)
+
+	result, err := in.Run(`let grow = fn(s) { grow(s + s) };
+try { grow("x") } catch (e) { e["message"] }`)
+	if err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+	if str, ok := result.(*object.String); !ok || str.Value != "memory limit of 65536 bytes exceeded" {
+		t.Errorf("wrong result. got=%v", result)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.mk": `let lib = import "lib.mk"; let answer = lib.value;`,
+		"lib.mk":  `let value = 42;`,
+		"bad.mk":  `let`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := New()
+	if _, err := in.RunFile(filepath.Join(dir, "main.mk")); err != nil {
+		t.Fatalf("RunFile returned error %v", err)
+	}
+
+	answer, ok := in.Get("answer")
+	if !ok {
+		t.Fatalf("answer not bound")
+	}
+	testInteger(t, answer, 42)
+
+	_, err = in.RunFile(filepath.Join(dir, "bad.mk"))
+	if err == nil || !strings.Contains(err.Error(), "bad.mk: parse error") {
+		t.Errorf("wrong error for bad.mk. got=%v", err)
+	}
+
+	if _, err := in.RunFile(filepath.Join(dir, "missing.mk")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	in.Run("let add = fn(a, b) { a + b }; let n = 1;")
+
+	result, err := in.Call("add", object.NewInteger(1), object.NewInteger(2))
+	if err != nil {
+		t.Fatalf("Call returned error %v", err)
+	}
+	testInteger(t, result, 3)
+
+	if _, err := in.Call("add", object.NewInteger(1)); err == nil || err.Error() != "wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong error for arity mismatch. got=%v", err)
+	}
+	if _, err := in.Call("n"); err == nil || err.Error() != "not a function: INTEGER" {
+		t.Errorf("wrong error for non-function. got=%v", err)
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Errorf("expected error for missing function")
+	}
+}
+
+func TestSetAndRegister(t *testing.T) {
+	in := New()
+	in.Set("base", object.NewInteger(10))
+	in.Register("twice", func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return &object.Error{Message: "twice takes one argument"}
+		}
+		n := args[0].(*object.Integer)
+		return object.NewInteger(n.Value() * 2)
+	})
+
+	result, err := in.Run("twice(base) + 1")
+	if err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+	testInteger(t, result, 21)
+
+	result, err = in.Run(`try { twice() } catch (e) { e["message"] }`)
+	if err != nil || result.Inspect() != "twice takes one argument" {
+		t.Errorf("registered error not catchable. got=%v, %v", result, err)
+	}
+}
+
+func TestReentrantCall(t *testing.T) {
+	in := New()
+	in.SetLimits(5000, 0)
+	in.Run(`let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) } };
+let twice = fn(n) { n * 2 };
+let loop = fn(n, f) { if (n == 0) { 0 } else { f(); loop(n - 1, f) } };`)
+
+	in.Register("call", func(name string, n int) (int, error) {
+		result, err := in.Call(name, object.NewInteger(int64(n)))
+		if err != nil {
+			return 0, err
+		}
+		return int(result.(*object.Integer).Value()), nil
+	})
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	in.Register("canceled", func() error {
+		_, err := in.CallContext(canceled, "twice", object.NewInteger(1))
+		return err
+	})
+
+	// the evaluation goes on, past its next look at the context, once the
+	// nested call returned
+	result, err := in.Run(`let v = call("twice", 3); loop(200, fn() { v }); v`)
+	if err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+	testInteger(t, result, 6)
+
+	result, err = in.Run(`try { canceled() } catch (e) { loop(200, fn() { 0 }); e["message"] }`)
+	if err != nil || result.Inspect() != "evaluation canceled: context canceled" {
+		t.Errorf("nested cancellation wrong. got=%v, %v", result, err)
+	}
+
+	// the steps of nested calls count against the budget of the evaluation,
+	// which stops even if the error the callback returned is caught
+	_, err = in.Run(`try { loop(100, fn() { call("spin", 20) }) } catch (e) { 0 }`)
+	if err == nil || !strings.Contains(err.Error(), "step limit of 5000 exceeded") {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+}
+
+func TestInterpretersAreIndependent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int64) {
+			defer wg.Done()
+
+			in := New()
+			in.Set("n", object.NewInteger(n))
+			result, err := in.Run(`
+			let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+			fib(15) + n`)
+			if err != nil {
+				t.Errorf("Run returned error %v", err)
+				return
+			}
+			testInteger(t, result, 610+n)
+		}(int64(n))
+	}
+
+	wg.Wait()
+}
+
+func testInteger(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return
+	}
+	if result.Value() != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value(), expected)
+	}
+}
@@ -5,10 +5,21 @@
 
 package object
 
-// Environment symbol table to track identifier and value binding
+import "sort"
+
+// Environment symbol table to track identifier and value binding. Globals
+// live in environments holding a map from names to values. Function calls and
+// catch blocks get frames instead, whose locals are stored in slots worked
+// out ahead of time by the resolver
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	// slots and their names, for frames
+	slots []Object
+	names []string
+	// inline holds the slots of small frames, saving an allocation per call
+	inline [2]Object
 }
 
 // NewEnclosedEnvironment create new environment used in enclosed block
@@ -24,17 +35,84 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
-// Get get symbol bound object and status
+// NewFrame create a frame enclosed by outer with a slot for each of names
+func NewFrame(outer *Environment, names []string) *Environment {
+	env := &Environment{outer: outer, names: names}
+	if len(names) <= len(env.inline) {
+		env.slots = env.inline[:len(names)]
+	} else {
+		env.slots = make([]Object, len(names))
+	}
+	return env
+}
+
+// Get get symbol bound object and status. Only globals are found by name,
+// frames are skipped
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[name]; ok {
+			return obj, true
+		}
 	}
-	return obj, ok
+	return nil, false
 }
 
 // Set bind object to symbol
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// GetSlot value of slot in the frame depth frames out, nil if it has not been
+// set yet
+func (e *Environment) GetSlot(depth, slot int) Object {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	return env.slots[slot]
+}
+
+// SetSlot bind val to slot in this frame
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.slots[slot] = val
+	return val
+}
+
+// Names list the symbols bound directly in this environment, sorted. For a
+// frame, these are the names of the slots that are set
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store)+len(e.slots))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer the environment this one is enclosed by, nil for the outermost
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Local the value bound to name directly in this environment, by name or in
+// a set slot of a frame
+func (e *Environment) Local(name string) (Object, bool) {
+	if obj, ok := e.store[name]; ok {
+		return obj, true
+	}
+	for i, slotName := range e.names {
+		if slotName == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+	return nil, false
+}
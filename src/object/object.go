@@ -10,6 +10,8 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -43,19 +45,62 @@ const (
 	ARRAYOBJ = "ARRAY"
 	// HASHOBJ hash object
 	HASHOBJ = "HASH"
+	// MODULEOBJ imported module object
+	MODULEOBJ = "MODULE"
+	// FLOATOBJ floating point object, produced by json_parse
+	FLOATOBJ = "FLOAT"
 )
 
-// Integer integer object
+// Integer integer object, made by NewInteger. Integers are immutable and
+// compared by value, never by identity, since small ones are shared
 type Integer struct {
-	Value int64
+	value int64
 }
 
+// range of the integers NewInteger does not allocate
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1023
+)
+
+var integers = func() []Integer {
+	cache := make([]Integer, maxCachedInteger-minCachedInteger+1)
+	for i := range cache {
+		cache[i].value = int64(i + minCachedInteger)
+	}
+	return cache
+}()
+
+// NewInteger return an Integer of value, shared with every other use of the
+// value when it is small
+func NewInteger(value int64) *Integer {
+	if value >= minCachedInteger && value <= maxCachedInteger {
+		return &integers[value-minCachedInteger]
+	}
+	return &Integer{value: value}
+}
+
+// Value the value of the integer
+func (i *Integer) Value() int64 { return i.value }
+
 // Inspect implement Object interface
-func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.value) }
 
 // Type implement Object interface
 func (i *Integer) Type() Type { return INTEGEROBJ }
 
+// Float floating point object. There are no float literals, floats come from
+// JSON and host values, and mix with integers in arithmetic and comparisons
+type Float struct {
+	Value float64
+}
+
+// Inspect implement Object interface
+func (f *Float) Inspect() string { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+// Type implement Object interface
+func (f *Float) Type() Type { return FLOATOBJ }
+
 // Boolean boolean object
 type Boolean struct {
 	Value bool
@@ -87,22 +132,136 @@ func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 // Type implement Object interface
 func (rv *ReturnValue) Type() Type { return RETURNVALUEOBJ }
 
+// ErrorKind tell the errors monkey code may catch from those stopping the
+// evaluation altogether
+type ErrorKind int
+
+const (
+	// RuntimeError raised by an operation or throw, may be caught
+	RuntimeError ErrorKind = iota
+	// StepLimit evaluation ran for more steps than allowed
+	StepLimit
+	// DepthLimit calls nested deeper than allowed
+	DepthLimit
+	// Canceled the context of the evaluation was canceled or timed out
+	Canceled
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case RuntimeError:
+		return "runtime error"
+	case StepLimit:
+		return "step limit"
+	case DepthLimit:
+		return "depth limit"
+	case Canceled:
+		return "canceled"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
 // Error error object
 type Error struct {
 	Message string
+	// Kind is RuntimeError unless the error stopped the evaluation
+	Kind ErrorKind
+	// Value holds the thrown object when raised by `throw`, nil otherwise
+	Value Object
+	// File is the path of the file of the expression that raised the error,
+	// empty when it was not read from a file
+	File string
+	// Line and Column locate the expression that raised the error, 0 if unknown
+	Line   int
+	Column int
+	// Stack is the call stack when the error was raised, outermost call first
+	Stack []Frame
 }
 
 // Inspect implement Object interface
 func (e *Error) Inspect() string { return "ERROR: " + e.Message }
 
+// Aborted report whether the error stopped the evaluation, in which case no
+// try expression could catch it
+func (e *Error) Aborted() bool { return e.Kind != RuntimeError }
+
+// Traceback render the error with the chain of calls that led to it, most
+// recent call last
+func (e *Error) Traceback() string {
+	if e.Line == 0 {
+		return e.Inspect()
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	// a line repeating the one before, as recursion makes, is only counted
+	last, repeated := "", 0
+	write := func(line string) {
+		if line == last {
+			repeated++
+			return
+		}
+		if repeated != 0 {
+			out.WriteString(fmt.Sprintf("  [previous line repeated %d more times]\n", repeated))
+		}
+		out.WriteString(line)
+		last, repeated = line, 0
+	}
+
+	// each frame records where its function was called from, which is a
+	// location inside the caller
+	function := "<main>"
+	for _, f := range e.Stack {
+		write(fmt.Sprintf("  %s, in %s\n", Position(f.File, f.Line, f.Column), function))
+		function = f.Function
+	}
+	write(fmt.Sprintf("  %s, in %s\n", Position(e.File, e.Line, e.Column), function))
+	write("") // ends the count of the last line
+	out.WriteString(e.Inspect())
+
+	return out.String()
+}
+
+// Position render a location in source as file:line:column, or as line and
+// column alone when file is empty
+func Position(file string, line, column int) string {
+	if file == "" {
+		return fmt.Sprintf("line %d, column %d", line, column)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, column)
+}
+
+// Frame one active function call of a monkey call stack
+type Frame struct {
+	// Function name of the called function, or <anonymous>
+	Function string
+	// File is the path of the file of the call site, empty when it was not
+	// read from a file
+	File string
+	// Line and Column locate the call site
+	Line   int
+	Column int
+}
+
 // Type implement Object interface
 func (e *Error) Type() Type { return ERROROBJ }
 
 // Function function object
 type Function struct {
+	// Name is the name the function literal was bound to by let, if any
+	Name       string
 	Parameters []*ast.Identifier
+	// ReturnType the type annotation of the result, nil if none
+	ReturnType *ast.TypeAnnotation
 	Body       *ast.BlockStatement
 	Env        *Environment
+	// File is the path of the file defining the function, empty when it was
+	// not read from a file
+	File string
+	// Locals names the slots of the frame of a call, parameters first
+	Locals []string
 }
 
 // Inspect implement Object interface
@@ -117,7 +276,11 @@ func (f *Function) Inspect() string {
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
+	if f.ReturnType != nil {
+		out.WriteString("-> " + f.ReturnType.String() + " ")
+	}
+	out.WriteString("{\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n")
 
@@ -155,6 +318,27 @@ func (b *Builtin) Type() Type { return BUILTINOBJ }
 // Array array object
 type Array struct {
 	Elements []Object
+
+	// used counts the elements of the backing array of Elements taken by the
+	// arrays made by Push, nil until the first Push
+	used *int
+}
+
+// Push return an array of the elements of a followed by obj. a is left as
+// is, but the new array shares its backing array when no other array was
+// pushed onto a yet, so that pushing in a loop takes amortized constant time
+func (a *Array) Push(obj Object) *Array {
+	n := len(a.Elements)
+	if a.used != nil && *a.used == n && n < cap(a.Elements) {
+		elements := a.Elements[:n+1]
+		elements[n] = obj
+		*a.used = n + 1
+		return &Array{Elements: elements, used: a.used}
+	}
+
+	elements := append(a.Elements[:n:n], obj)
+	used := n + 1
+	return &Array{Elements: elements, used: &used}
 }
 
 // Inspect implement Object interface
@@ -197,7 +381,16 @@ func (b *Boolean) HashKey() HashKey {
 
 // HashKey integer object hashable
 func (i *Integer) HashKey() HashKey {
-	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+	return HashKey{Type: i.Type(), Value: uint64(i.value)}
+}
+
+// HashKey float object hashable. A float equal to an integer has the key of
+// the integer, since the two compare equal
+func (f *Float) HashKey() HashKey {
+	if i := int64(f.Value); float64(i) == f.Value && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return NewInteger(i).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
 }
 
 // HashKey string object hashable
@@ -242,3 +435,18 @@ func (h *Hash) Type() Type { return HASHOBJ }
 type Hashable interface {
 	HashKey() HashKey
 }
+
+// Module imported module, exposing the top-level bindings of its file
+type Module struct {
+	// Name is the path as written in the import expression
+	Name string
+	// Path is the resolved absolute path of the module file
+	Path string
+	Env  *Environment
+}
+
+// Inspect implement Object interface
+func (m *Module) Inspect() string { return fmt.Sprintf("module(%s)", m.Name) }
+
+// Type implement Object interface
+func (m *Module) Type() Type { return MODULEOBJ }
@@ -27,3 +27,162 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	two := &Float{Value: 2}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+
+	if two.HashKey() != NewInteger(2).HashKey() {
+		t.Errorf("float 2 and integer 2 have different hash keys")
+	}
+
+	if half1.HashKey() == two.HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{
+		Message: "identifier not found: foo",
+		Line:    2,
+		Column:  4,
+		Stack: []Frame{
+			{Function: "outer", Line: 7, Column: 6},
+			{Function: "<anonymous>", Line: 5, Column: 13},
+		},
+	}
+
+	expected := `Traceback (most recent call last):
+  line 7, column 6, in <main>
+  line 5, column 13, in outer
+  line 2, column 4, in <anonymous>
+ERROR: identifier not found: foo`
+
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expected, err.Traceback())
+	}
+
+	imported := &Error{
+		Message: "boom",
+		File:    "lib/bad.mk",
+		Line:    2,
+		Column:  5,
+		Stack:   []Frame{{Function: "f", File: "main.mk", Line: 3, Column: 2}},
+	}
+
+	expected = `Traceback (most recent call last):
+  main.mk:3:2, in <main>
+  lib/bad.mk:2:5, in f
+ERROR: boom`
+
+	if imported.Traceback() != expected {
+		t.Errorf("wrong traceback with files. expected=%q, got=%q", expected, imported.Traceback())
+	}
+
+	recursive := &Error{
+		Message: "call depth limit of 4 exceeded",
+		Line:    1,
+		Column:  22,
+		Stack: []Frame{
+			{Function: "f", Line: 1, Column: 34},
+			{Function: "f", Line: 1, Column: 22},
+			{Function: "f", Line: 1, Column: 22},
+			{Function: "f", Line: 1, Column: 22},
+		},
+	}
+
+	expected = `Traceback (most recent call last):
+  line 1, column 34, in <main>
+  line 1, column 22, in f
+  [previous line repeated 3 more times]
+ERROR: call depth limit of 4 exceeded`
+
+	if recursive.Traceback() != expected {
+		t.Errorf("wrong traceback of recursion. expected=%q, got=%q", expected, recursive.Traceback())
+	}
+
+	bare := &Error{Message: "boom"}
+	if bare.Traceback() != "ERROR: boom" {
+		t.Errorf("wrong traceback for error without position. got=%q", bare.Traceback())
+	}
+}
+
+func TestArrayPush(t *testing.T) {
+	empty := &Array{}
+	a := empty.Push(NewInteger(1))
+	b := a.Push(NewInteger(2))
+	c := b.Push(NewInteger(3))
+	// b was pushed onto already, d must not clobber c's third element
+	d := b.Push(NewInteger(4))
+
+	tests := []struct {
+		array    *Array
+		expected string
+	}{
+		{empty, "[]"},
+		{a, "[1]"},
+		{b, "[1, 2]"},
+		{c, "[1, 2, 3]"},
+		{d, "[1, 2, 4]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.array.Inspect(); got != tt.expected {
+			t.Errorf("wrong array. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	for _, value := range []int64{-1000, minCachedInteger, -1, 0, 1, maxCachedInteger, 5000} {
+		a, b := NewInteger(value), NewInteger(value)
+		if a.Value() != value || b.Value() != value {
+			t.Errorf("NewInteger(%d) has wrong value. got=%d, %d", value, a.Value(), b.Value())
+		}
+
+		cached := value >= minCachedInteger && value <= maxCachedInteger
+		if (a == b) != cached {
+			t.Errorf("NewInteger(%d) shared=%t, expected %t", value, a == b, cached)
+		}
+	}
+}
+
+func TestEnvironmentLocal(t *testing.T) {
+	globals := NewEnvironment()
+	globals.Set("g", NewInteger(1))
+	frame := NewFrame(globals, []string{"a", "b"})
+	frame.SetSlot(0, NewInteger(2))
+
+	if frame.Outer() != globals || globals.Outer() != nil {
+		t.Errorf("Outer wrong")
+	}
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64
+		found    bool
+	}{
+		{globals, "g", 1, true},
+		{frame, "a", 2, true},
+		// unset slots and names of outer environments are not local
+		{frame, "b", 0, false},
+		{frame, "g", 0, false},
+	}
+
+	for _, tt := range tests {
+		obj, ok := tt.env.Local(tt.name)
+		if ok != tt.found {
+			t.Errorf("Local(%q) found=%t, expected %t", tt.name, ok, tt.found)
+			continue
+		}
+		if ok && obj.(*Integer).Value() != tt.expected {
+			t.Errorf("Local(%q) wrong. expected=%d, got=%s", tt.name, tt.expected, obj.Inspect())
+		}
+	}
+}
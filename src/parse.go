@@ -0,0 +1,90 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"ast"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"format"
+	"io"
+	"io/ioutil"
+	"lexer"
+	"parser"
+)
+
+const parseUsage = `usage: monkey parse [-json] [file | -]
+
+Parse file, or standard input, and print its syntax tree: as JSON with -json,
+otherwise as canonically formatted source. Parse errors are reported on
+stderr.
+
+flags:
+`
+
+// runParse the parse subcommand
+func runParse(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey parse", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, parseUsage)
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the tree as JSON, with kinds, tokens and positions")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path := "-"
+	if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	}
+
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = ioutil.ReadAll(stdin)
+	} else {
+		src, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey parse: %s\n", err)
+		return exitUsage
+	}
+
+	p := parser.New(lexer.New(lexer.SkipShebang(string(src))))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "parse error: %s\n", msg)
+		}
+		return exitUsage
+	}
+
+	if !*asJSON {
+		io.WriteString(stdout, format.Node(program)+"\n")
+		return 0
+	}
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey parse: %s\n", err)
+		return exitRuntimeError
+	}
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	out.WriteString("\n")
+	out.WriteTo(stdout)
+
+	return 0
+}
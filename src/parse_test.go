@@ -0,0 +1,75 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"ast"
+	"bytes"
+	"lexer"
+	"parser"
+	"strings"
+	"testing"
+)
+
+func TestRunParse(t *testing.T) {
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"parse"}, "let x=1", 0, "let x = 1;\n", ""},
+		{[]string{"parse", "-json", "-"}, "x", 0, `"kind": "ExpressionStatement"`, ""},
+		{[]string{"parse", "--json"}, "let = 1", exitUsage, "", "parse error: expected next token to be IDENT"},
+		{[]string{"parse", "a.mk", "b.mk"}, "", exitUsage, "", "usage: monkey parse"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q) exit code wrong. expected=%d, got=%d", tt.args, tt.expectedCode, code)
+		}
+		if !strings.Contains(stdout.String(), tt.expectedStdout) {
+			t.Errorf("run(%q) stdout wrong. expected to contain %q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("run(%q) stderr wrong. expected to contain %q, got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+// TestParseJSONRoundTrip decodes the output of parse -json back into the
+// program that was parsed
+func TestParseJSONRoundTrip(t *testing.T) {
+	src := `let f = fn(a, b) { if (a > b) { return a; } else { b } };
+let h = {"k": [1, -2, true], 3: f(1, 2)["x"]};
+let m = import "lib";
+try { throw m.v } catch (e) { puts(e) } finally { 0 }`
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"parse", "-json"}, strings.NewReader(src), &stdout, &stderr); code != 0 {
+		t.Fatalf("parse -json failed: %s", stderr.String())
+	}
+
+	node, err := ast.DecodeJSON(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeJSON returned error: %s", err)
+	}
+
+	program := parser.New(lexer.New(src)).ParseProgram()
+	if !ast.Equal(node, program) {
+		t.Errorf("decoded program wrong. expected=%q, got=%q", program.String(), node.String())
+	}
+
+	expected, _ := ast.EncodeJSON(program)
+	got, _ := ast.EncodeJSON(node)
+	if !bytes.Equal(expected, got) {
+		t.Errorf("round trip is lossy.\nexpected=%s\ngot=%s", expected, got)
+	}
+}
@@ -37,12 +37,17 @@ const (
 type Parser struct {
 	l      *lexer.Lexer
 	errors []string
+	// errorTokens the tokens errors were found at, one per error
+	errorTokens []token.Token
 
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	// indent nesting depth of the trace output
+	indent int
 }
 
 type (
@@ -67,6 +72,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -79,6 +86,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndixExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// read two token, so curToken and peekToken are both set
 	p.nextToken()
@@ -100,9 +108,20 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorTokens the tokens the errors were found at, in the order of Errors
+func (p *Parser) ErrorTokens() []token.Token {
+	return p.errorTokens
+}
+
+// addError record msg, found at tok
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorTokens = append(p.errorTokens, tok)
+}
+
 func (p *Parser) peekError(t token.Type) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) nextToken() {
@@ -112,7 +131,7 @@ func (p *Parser) nextToken() {
 
 // ParseProgram parse AST
 func (p *Parser) ParseProgram() *ast.Program {
-	defer untrace(trace("ParseProgram"))
+	defer p.untrace(p.trace("ParseProgram"))
 
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
@@ -128,7 +147,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	defer untrace(trace("ParseIdentifier"))
+	defer p.untrace(p.trace("ParseIdentifier"))
 
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -139,20 +158,22 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	defer untrace(trace("ParseIntegerLiteral"))
+	defer p.untrace(p.trace("ParseIntegerLiteral"))
 
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -161,7 +182,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	defer untrace(trace("ParseLetStatement"))
+	defer p.untrace(p.trace("ParseLetStatement"))
 
 	stmt := &ast.LetStatement{Token: p.curToken}
 
@@ -171,6 +192,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Name.Type = p.parseTypeAnnotation(); stmt.Name.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -179,6 +208,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -187,7 +220,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	defer untrace(trace("ParseReturnStatement"))
+	defer p.untrace(p.trace("ParseReturnStatement"))
 
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -201,6 +234,21 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	defer p.untrace(p.trace("ParseThrowStatement"))
+
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -208,17 +256,23 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.addError(p.curToken, "expected next token to be }, got EOF instead")
+			return block
+		}
+
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 	return block
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	defer untrace(trace("ParseExpressionStatement"))
+	defer p.untrace(p.trace("ParseExpressionStatement"))
 
 	stmt := &ast.ExpressionStatement{
 		Token: p.curToken,
@@ -246,7 +300,7 @@ func (p *Parser) parseGroupedExression() ast.Expression {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	defer untrace(trace("ParseExpression"))
+	defer p.untrace(p.trace("ParseExpression"))
 
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
@@ -300,8 +354,60 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		msg := fmt.Sprintf("expected catch or finally after try block, got %s instead", p.peekToken.Type)
+		p.addError(p.peekToken, msg)
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	defer untrace(trace("ParsePrefixExpresion"))
+	defer p.untrace(p.trace("ParsePrefixExpresion"))
 
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -316,7 +422,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.Type) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) curTokenIs(t token.Type) bool {
@@ -337,7 +443,7 @@ func (p *Parser) expectPeek(t token.Type) bool {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	defer untrace(trace("ParseInfixExpression"))
+	defer p.untrace(p.trace("ParseInfixExpression"))
 
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -363,6 +469,16 @@ var precedences = map[token.Type]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+}
+
+// Precedence binding power of the infix operator t, LOWEST if t is not one
+func Precedence(t token.Type) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -394,6 +510,14 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 
 	lit.Parameters = p.parseFunctionParameters()
 
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -413,14 +537,20 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := p.parseFunctionParameter()
+	if ident == nil {
+		return nil
+	}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
 
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident := p.parseFunctionParameter()
+		if ident == nil {
+			return nil
+		}
 		identifiers = append(identifiers, ident)
 	}
 
@@ -431,9 +561,86 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseFunctionParameter <identifier> or <identifier>: <type>
+func (p *Parser) parseFunctionParameter() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if ident.Type = p.parseTypeAnnotation(); ident.Type == nil {
+			return nil
+		}
+	}
+
+	return ident
+}
+
+// typeParams number of element types each type name may take, array taking
+// that of its elements and hash those of its keys and values
+var typeParams = map[string]int{
+	"any":    0,
+	"int":    0,
+	"float":  0,
+	"string": 0,
+	"bool":   0,
+	"null":   0,
+	"fn":     0,
+	"array":  1,
+	"hash":   2,
+}
+
+// parseTypeAnnotation <name> or <name><<type>, ...>. Element types may be
+// left out, array alone being an array of anything
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.FUNCTION) {
+		msg := fmt.Sprintf("expected a type, got %s instead", p.curToken.Type)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+
+	ta := &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+	want, ok := typeParams[ta.Name]
+	if !ok {
+		p.addError(ta.Token, fmt.Sprintf("unknown type %s", ta.Name))
+		return nil
+	}
+
+	if !p.peekTokenIs(token.LT) {
+		return ta
+	}
+	p.nextToken()
+
+	for {
+		p.nextToken()
+		param := p.parseTypeAnnotation()
+		if param == nil {
+			return nil
+		}
+		ta.Params = append(ta.Params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.GT) {
+		return nil
+	}
+
+	if len(ta.Params) != want {
+		msg := fmt.Sprintf("wrong number of element types for %s: expected %d, got %d", ta.Name, want, len(ta.Params))
+		p.addError(ta.Token, msg)
+		return nil
+	}
+	return ta
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
@@ -445,6 +652,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 
 	return array
 }
@@ -486,6 +694,30 @@ func (p *Parser) parseIndixExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	exp.Path = p.curToken.Literal
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -502,6 +734,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -511,18 +744,17 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 	return hash
 }
 
-var indent = 0
-
-func trace(s string) string {
-	// fmt.Printf("%sBEGIN: %s\n", strings.Repeat("  ", indent), s)
-	indent++
+func (p *Parser) trace(s string) string {
+	// fmt.Printf("%sBEGIN: %s\n", strings.Repeat("  ", p.indent), s)
+	p.indent++
 	return s
 }
 
-func untrace(s string) {
-	// fmt.Printf("%sEND: %s\n", strings.Repeat("  ", indent-1), s)
-	indent--
+func (p *Parser) untrace(s string) {
+	// fmt.Printf("%sEND: %s\n", strings.Repeat("  ", p.indent-1), s)
+	p.indent--
 }
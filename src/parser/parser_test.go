@@ -804,3 +804,211 @@ func TestParsingHashLiteralWithExpression(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestThrowStatement(t *testing.T) {
+	input := `throw "oops" + x;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Value.String() != "(oops + x)" {
+		t.Errorf("stmt.Value is not %s. got=%s", "(oops + x)", stmt.Value.String())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		hasCatch   bool
+		hasFinally bool
+	}{
+		{"try { x } catch (e) { e }", true, false},
+		{"try { x } finally { y }", false, true},
+		{"try { x } catch (e) { e } finally { y }", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if len(exp.Block.Statements) != 1 || !testIdentifier(t, exp.Block.Statements[0].(*ast.ExpressionStatement).Expression, "x") {
+			t.Fatalf("try block is wrong. got=%s", exp.Block.String())
+		}
+
+		if (exp.Catch != nil) != tt.hasCatch {
+			t.Errorf("exp.Catch presence wrong. want=%t, got=%+v", tt.hasCatch, exp.Catch)
+		}
+		if tt.hasCatch && !testIdentifier(t, exp.Param, "e") {
+			return
+		}
+
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("exp.Finally presence wrong. want=%t, got=%+v", tt.hasFinally, exp.Finally)
+		}
+	}
+}
+
+func TestTryExpressionWithoutHandler(t *testing.T) {
+	l := lexer.New("try { x }")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser error for try without catch or finally")
+	}
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
+func TestImportAndMemberExpression(t *testing.T) {
+	input := `import "lib/strings.mk".upper(s)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	member, ok := call.Function.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("call.Function is not ast.MemberExpression. got=%T", call.Function)
+	}
+
+	if !testIdentifier(t, member.Property, "upper") {
+		return
+	}
+
+	imp, ok := member.Object.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("member.Object is not ast.ImportExpression. got=%T", member.Object)
+	}
+
+	if imp.Path != "lib/strings.mk" {
+		t.Errorf("imp.Path is not %q. got=%q", "lib/strings.mk", imp.Path)
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: array<int> = [];", "let xs: array<int> = [];"},
+		{"let h: hash<string, array<int>> = {};", "let h: hash<string, array<int>> = {};"},
+		{"fn(a: string, b: array) -> bool { true }", "fn(a: string, b: array) -> bool { true }"},
+		{"fn(a, b: fn) -> null { }", "fn(a, b: fn) -> null {  }"},
+		{"fn(a) { a }", "fn(a) { a }"},
+		{"let f = fn() -> any { 1 - 2 };", "let f = fn() -> any { (1 - 2) };"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: number = 5;", "unknown type number"},
+		{"let x: 5 = 5;", "expected a type, got INT instead"},
+		{"fn(a: array<int, int>) { a }", "wrong number of element types for array: expected 1, got 2"},
+		{"let h: hash<string> = {};", "wrong number of element types for hash: expected 2, got 1"},
+		{"let n: int<int> = 1;", "wrong number of element types for int: expected 0, got 1"},
+		{"fn() -> array<int { 1 }", "expected next token to be >, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("parser errors wrong for %q. expected=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestErrorTokens(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"let = 5;", 1, 5},
+		{"let x = 5;\nlet y 6;", 2, 7},
+		{"let x = );", 1, 9},
+		{"fn() {\n  1", 2, 4},
+		{"try { 1 }", 1, 10},
+		{"let x: number = 5;", 1, 8},
+		{"99999999999999999999", 1, 1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.ErrorTokens()) != len(p.Errors()) {
+			t.Fatalf("%q: got %d error tokens for %d errors", tt.input, len(p.ErrorTokens()), len(p.Errors()))
+		}
+		tok := p.ErrorTokens()[0]
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("%q: error position wrong. expected=%d:%d, got=%d:%d",
+				tt.input, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
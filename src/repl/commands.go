@@ -0,0 +1,201 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package repl
+
+import (
+	"ast"
+	"fmt"
+	"io/ioutil"
+	"lexer"
+	"object"
+	"parser"
+	"reflect"
+	"sort"
+	"strings"
+	"token"
+)
+
+// command REPL meta-command, entered as :<name> [argument]
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *Session, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"env", ":env", "list the bindings of the session", (*Session).commandEnv},
+		{"tokens", ":tokens <src>", "print the tokens of src", (*Session).commandTokens},
+		{"ast", ":ast <src>", "print the syntax tree of src", (*Session).commandAST},
+		{"type", ":type <expr>", "evaluate expr and print the type of its value", (*Session).commandType},
+		{"load", ":load <file>", "evaluate file in the session", (*Session).commandLoad},
+		{"reset", ":reset", "discard all bindings and imported modules", (*Session).commandReset},
+		{"help", ":help", "list the meta-commands", (*Session).commandHelp},
+	}
+}
+
+// command run the meta-command line, which starts with ':'
+func (s *Session) command(line string) {
+	name, arg := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			c.run(s, arg)
+			return
+		}
+	}
+
+	fmt.Fprintf(s.Err, "unknown command :%s, type :help for a list\n", name)
+}
+
+func (s *Session) commandEnv(arg string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.Out, "%s: %s = %s\n", name, val.Type(), summary(val))
+	}
+}
+
+// summary one line description of obj
+func summary(obj object.Object) string {
+	if fn, ok := obj.(*object.Function); ok {
+		params := []string{}
+		for _, p := range fn.Parameters {
+			params = append(params, p.String())
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	}
+	return obj.Inspect()
+}
+
+func (s *Session) commandTokens(arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(s.Out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+func (s *Session) commandAST(arg string) {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.Err, p.Errors())
+		return
+	}
+
+	s.dumpNode("", reflect.ValueOf(program), 0)
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// dumpNode print the tree rooted at v, one node per line. Scalar fields are
+// printed next to the node type, child nodes on the following lines
+func (s *Session) dumpNode(label string, v reflect.Value, depth int) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.IsNil() {
+		return
+	}
+
+	line := strings.Repeat("  ", depth) + label + v.Elem().Type().Name()
+
+	st := v.Elem()
+	var children []reflect.StructField
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Type().Field(i)
+		switch {
+		case f.Type == reflect.TypeOf(token.Token{}), f.Tag.Get("json") == "-":
+			// positions and the annotations of the resolver are left out
+		case f.Type.Kind() == reflect.String, f.Type.Kind() == reflect.Int64, f.Type.Kind() == reflect.Bool:
+			line += fmt.Sprintf(" %s=%#v", f.Name, st.Field(i).Interface())
+		default:
+			children = append(children, f)
+		}
+	}
+	fmt.Fprintln(s.Out, line)
+
+	for _, f := range children {
+		field := st.FieldByIndex(f.Index)
+		switch field.Kind() {
+		case reflect.Slice:
+			for i := 0; i < field.Len(); i++ {
+				s.dumpNode(fmt.Sprintf("%s[%d]: ", f.Name, i), field.Index(i), depth+1)
+			}
+		case reflect.Map:
+			keys := field.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].Interface().(ast.Node).String() < keys[j].Interface().(ast.Node).String()
+			})
+			for _, key := range keys {
+				s.dumpNode("Key: ", key, depth+1)
+				s.dumpNode("Value: ", field.MapIndex(key), depth+1)
+			}
+		default:
+			if field.Type().Implements(nodeType) {
+				s.dumpNode(f.Name+": ", field, depth+1)
+			}
+		}
+	}
+}
+
+func (s *Session) commandType(arg string) {
+	program := s.parse(arg)
+	if program == nil {
+		return
+	}
+
+	evaluated := s.Evaluator.Eval(program, s.env)
+	switch {
+	case evaluated == nil:
+		// let statements, for instance, evaluate to nothing
+		fmt.Fprintf(s.Err, "%s has no value\n", arg)
+	case isError(evaluated):
+		s.print(evaluated)
+	default:
+		fmt.Fprintln(s.Out, evaluated.Type())
+	}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func (s *Session) commandLoad(arg string) {
+	src, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.Err, "could not load %s: %s\n", arg, err)
+		return
+	}
+
+	file := s.Evaluator.File
+	s.Evaluator.File = arg
+	defer func() { s.Evaluator.File = file }()
+
+	if evaluated := s.run(lexer.SkipShebang(string(src))); evaluated != nil && isError(evaluated) {
+		s.print(evaluated)
+	}
+}
+
+func (s *Session) commandReset(arg string) {
+	s.env = object.NewEnclosedEnvironment(s.Env)
+	s.Evaluator.Reset()
+}
+
+func (s *Session) commandHelp(arg string) {
+	for _, c := range commands {
+		fmt.Fprintf(s.Out, "%-16s%s\n", c.usage, c.help)
+	}
+}
@@ -0,0 +1,417 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt returned by readLine when the user abandons the line with Ctrl-C
+var errInterrupt = errors.New("interrupted")
+
+// maxHistory number of history entries kept in memory and on disk
+const maxHistory = 1000
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlY     = 25
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// key decoded key press: a rune, or one of the named keys of escape sequences
+type key struct {
+	r    rune
+	name string
+}
+
+// editor minimal emacs-style line editor for terminals in raw mode. It
+// supports cursor movement, kill and yank, history navigation, reverse
+// incremental search with Ctrl-R and tab completion
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	history  []string
+	complete func(prefix string) []string
+
+	prompt string
+	buf    []rune
+	pos    int
+	yank   []rune
+
+	// unread key to be returned again by the next readKey
+	unread *key
+}
+
+func newEditor(in io.Reader, out io.Writer, complete func(prefix string) []string) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, complete: complete}
+}
+
+// addHistory remember line for history navigation and search
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// readLine edit a line after showing prompt. It returns io.EOF on Ctrl-D in
+// an empty line and errInterrupt on Ctrl-C
+func (e *editor) readLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+
+	// index into history while browsing it, len(history) is the line being
+	// edited, which is saved in pending
+	index := len(e.history)
+	var pending []rune
+
+	e.refresh()
+
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case k.r == keyCR || k.r == keyLF:
+			io.WriteString(e.out, "\r\n")
+			return string(e.buf), nil
+		case k.r == keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupt
+		case k.r == keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRange(e.pos, e.pos+1)
+		case k.r == keyCtrlA || k.name == "home":
+			e.pos = 0
+		case k.r == keyCtrlE || k.name == "end":
+			e.pos = len(e.buf)
+		case k.r == keyCtrlB || k.name == "left":
+			if e.pos > 0 {
+				e.pos--
+			}
+		case k.r == keyCtrlF || k.name == "right":
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case k.name == "word-left":
+			e.pos = e.wordStart(e.pos)
+		case k.name == "word-right":
+			e.pos = e.wordEnd(e.pos)
+		case k.r == keyBackspace || k.r == keyDelete:
+			if e.pos > 0 {
+				e.deleteRange(e.pos-1, e.pos)
+			}
+		case k.name == "delete":
+			e.deleteRange(e.pos, e.pos+1)
+		case k.r == keyCtrlK:
+			e.kill(e.pos, len(e.buf))
+		case k.r == keyCtrlU:
+			e.kill(0, e.pos)
+		case k.r == keyCtrlW:
+			e.kill(e.wordStart(e.pos), e.pos)
+		case k.r == keyCtrlY:
+			e.insert(e.yank...)
+		case k.r == keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case k.r == keyCtrlP || k.name == "up":
+			if index > 0 {
+				if index == len(e.history) {
+					pending = append(pending[:0], e.buf...)
+				}
+				index--
+				e.setLine(e.history[index])
+			}
+		case k.r == keyCtrlN || k.name == "down":
+			if index < len(e.history) {
+				index++
+				if index == len(e.history) {
+					e.setLine(string(pending))
+				} else {
+					e.setLine(e.history[index])
+				}
+			}
+		case k.r == keyCtrlR:
+			line, accept, err := e.search()
+			if err != nil {
+				return "", err
+			}
+			e.setLine(line)
+			if accept {
+				e.refresh()
+				io.WriteString(e.out, "\r\n")
+				return line, nil
+			}
+		case k.r == keyTab:
+			e.completeWord()
+		case k.name == "" && unicode.IsPrint(k.r):
+			e.insert(k.r)
+		}
+
+		e.refresh()
+	}
+}
+
+// search run a reverse incremental search through the history. It returns the
+// matched line and whether it was accepted with enter
+func (e *editor) search() (string, bool, error) {
+	var query []rune
+	original := string(e.buf)
+	match, index := "", len(e.history)
+
+	// find the newest entry at or before from that contains the query
+	find := func(from int) bool {
+		if from >= len(e.history) {
+			from = len(e.history) - 1
+		}
+		for i := from; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, index = e.history[i], i
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), match)
+
+		k, err := e.readKey()
+		if err != nil {
+			return "", false, err
+		}
+
+		switch {
+		case k.r == keyCR || k.r == keyLF:
+			return match, true, nil
+		case k.r == keyCtrlG || k.r == keyCtrlC:
+			return original, false, nil
+		case k.r == keyCtrlR:
+			find(index - 1)
+		case k.r == keyBackspace || k.r == keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match, index = "", len(e.history)
+				find(index)
+			}
+		case k.name == "" && unicode.IsPrint(k.r):
+			query = append(query, k.r)
+			if !find(index) {
+				match = ""
+			}
+		default:
+			// any other key leaves the search with the match in the line
+			// and is then handled as usual
+			e.unread = &k
+			return match, false, nil
+		}
+	}
+}
+
+// completeWord complete the identifier before the cursor. A unique candidate
+// is inserted, otherwise the common prefix is, and the candidates are listed
+// when there is nothing more to insert
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && isIdentRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):])...)
+		return
+	}
+
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+func (e *editor) insert(rs ...rune) {
+	buf := make([]rune, 0, len(e.buf)+len(rs))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, rs...)
+	buf = append(buf, e.buf[e.pos:]...)
+	e.buf = buf
+	e.pos += len(rs)
+}
+
+func (e *editor) deleteRange(from, to int) {
+	if to > len(e.buf) {
+		to = len(e.buf)
+	}
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+}
+
+// kill delete the runes between from and to, saving them for yank
+func (e *editor) kill(from, to int) {
+	if from >= to {
+		return
+	}
+	e.yank = append(e.yank[:0], e.buf[from:to]...)
+	e.deleteRange(from, to)
+}
+
+func (e *editor) setLine(line string) {
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+func (e *editor) wordStart(pos int) int {
+	for pos > 0 && !isIdentRune(e.buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isIdentRune(e.buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+func (e *editor) wordEnd(pos int) int {
+	for pos < len(e.buf) && !isIdentRune(e.buf[pos]) {
+		pos++
+	}
+	for pos < len(e.buf) && isIdentRune(e.buf[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// refresh redraw the prompt and line, and put the cursor in place
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", e.prompt, string(e.buf))
+	if n := len([]rune(e.prompt)) + e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", n)
+	}
+}
+
+// readKey read one key press, decoding the escape sequences of arrow keys,
+// home, end and delete
+func (e *editor) readKey() (key, error) {
+	if e.unread != nil {
+		k := *e.unread
+		e.unread = nil
+		return k, nil
+	}
+
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if r != keyEscape {
+		return key{r: r}, nil
+	}
+
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch next {
+	case 'b':
+		return key{name: "word-left"}, nil
+	case 'f':
+		return key{name: "word-right"}, nil
+	case '[', 'O':
+	default:
+		return key{name: "unknown"}, nil
+	}
+
+	var seq []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return key{name: "up"}, nil
+	case "B":
+		return key{name: "down"}, nil
+	case "C":
+		return key{name: "right"}, nil
+	case "D":
+		return key{name: "left"}, nil
+	case "H", "1~", "7~":
+		return key{name: "home"}, nil
+	case "F", "4~", "8~":
+		return key{name: "end"}, nil
+	case "3~":
+		return key{name: "delete"}, nil
+	case "1;5D", "1;3D":
+		return key{name: "word-left"}, nil
+	case "1;5C", "1;3C":
+		return key{name: "word-right"}, nil
+	}
+
+	return key{name: "unknown"}, nil
+}
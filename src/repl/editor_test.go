@@ -0,0 +1,98 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package repl
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 5;\r", "let x = 5;"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abc\x1b[D\x1b[Dx\x1b[Hy\x1b[Fz\r", "yaxbcz"},
+		{"abcd\x08\x08\r", "ab"},
+		{"abcd\x01\x1b[3~\x04\r", "cd"},
+		{"foo bar\x17baz\r", "foo baz"},
+		{"foo bar\x01\x06\x06\x06\x0b\x19\x19\r", "foo bar bar"},
+		{"foo bar\x15x\x19\r", "xfoo bar"},
+		{"one two\x1bbX\r", "one Xtwo"},
+		{"bound\x1b[A\r", "puts(x)"},
+		{"\x1b[A\x1b[A\x1b[B\r", "puts(x)"},
+		{"draft\x1b[A\x1b[B\r", "draft"},
+		{"\x12x =\r", "let x = 5;"},
+		{"\x12x\x12\r", "let x = 5;"},
+		{"\x12put\x1b[D(\r", "puts(x()"},
+		{"\x12nomatch\r", ""},
+		{"keep\x12x\x07!\r", "keep!"},
+		{"le\t\r", "let"},
+		{"b\t\r", "bound"},
+		{"p\t(\r", "pu("},
+	}
+
+	for _, tt := range tests {
+		e := newEditor(strings.NewReader(tt.keys), ioutil.Discard, func(prefix string) []string {
+			var names []string
+			for _, name := range []string{"bound", "let", "push", "puts"} {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			return names
+		})
+		e.addHistory("let x = 5;")
+		e.addHistory("puts(x)")
+
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("readLine(%q) returned error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("readLine(%q) wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorControl(t *testing.T) {
+	e := newEditor(strings.NewReader("abc\x03\x04"), ioutil.Discard, nil)
+
+	if _, err := e.readLine(PROMPT); err != errInterrupt {
+		t.Errorf("expected errInterrupt on Ctrl-C. got=%v", err)
+	}
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("expected io.EOF on Ctrl-D in empty line. got=%v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	e := newEditor(strings.NewReader(""), ioutil.Discard, nil)
+
+	for _, line := range []string{"a", "", "  ", "b", "b", "a"} {
+		e.addHistory(line)
+	}
+
+	expected := []string{"a", "b", "a"}
+	if strings.Join(e.history, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong history. expected=%q, got=%q", expected, e.history)
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	s := NewSession(strings.NewReader("let format = 1; let first_name = 2;"), ioutil.Discard, ioutil.Discard)
+	s.Run()
+
+	expected := []string{"finally", "first", "first_name"}
+	if got := s.complete("fi"); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong completions. expected=%q, got=%q", expected, got)
+	}
+}
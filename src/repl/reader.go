@@ -0,0 +1,139 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyFile name of the history file in the user's home directory
+const historyFile = ".monkey_history"
+
+// lineReader source of REPL input lines
+type lineReader interface {
+	// readLine read the next line after showing prompt
+	readLine(prompt string) (string, error)
+	// addHistory record an entered line
+	addHistory(line string)
+	close()
+}
+
+// newLineReader use the line editor when the session is interactive, both In
+// and Out are terminals and the platform supports line editing, plain line
+// reading otherwise
+func (s *Session) newLineReader() lineReader {
+	scan := &scanReader{scanner: bufio.NewScanner(s.In)}
+	if s.Interactive {
+		scan.prompts = s.Out
+	}
+
+	inFile, ok := s.In.(*os.File)
+	if !lineEditing || !s.Interactive || !ok || !isTerminal(inFile.Fd()) {
+		return scan
+	}
+	if outFile, ok := s.Out.(*os.File); !ok || !isTerminal(outFile.Fd()) {
+		return scan
+	}
+
+	t := &termReader{fd: inFile.Fd(), editor: newEditor(s.In, s.Out, s.complete)}
+	t.loadHistory()
+	return t
+}
+
+// scanReader read lines from a non-interactive input
+type scanReader struct {
+	scanner *bufio.Scanner
+	// prompts receives the prompts, nil to suppress them
+	prompts io.Writer
+}
+
+func (r *scanReader) readLine(prompt string) (string, error) {
+	if r.prompts != nil {
+		io.WriteString(r.prompts, prompt)
+	}
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *scanReader) addHistory(line string) {}
+
+func (r *scanReader) close() {}
+
+// termReader edit lines on a terminal, keeping history in the user's home
+// directory
+type termReader struct {
+	fd      uintptr
+	editor  *editor
+	history *os.File
+}
+
+func (r *termReader) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	return r.editor.readLine(prompt)
+}
+
+func (r *termReader) addHistory(line string) {
+	n := len(r.editor.history)
+	r.editor.addHistory(line)
+	if r.history != nil && len(r.editor.history) != n {
+		fmt.Fprintln(r.history, line)
+	}
+}
+
+func (r *termReader) close() {
+	if r.history != nil {
+		r.history.Close()
+	}
+}
+
+// loadHistory read the history file and keep it open for appending. History
+// is best effort, a missing home directory only disables persistence
+func (r *termReader) loadHistory() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(home, historyFile)
+
+	data, _ := ioutil.ReadFile(path)
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		r.editor.addHistory(line)
+	}
+
+	// rewrite the file when it has grown past what is kept
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if len(lines) > maxHistory {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return
+	}
+	if flags&os.O_TRUNC != 0 {
+		for _, line := range r.editor.history {
+			fmt.Fprintln(f, line)
+		}
+	}
+	r.history = f
+}
@@ -6,48 +6,211 @@
 package repl
 
 import (
-	"bufio"
+	"ast"
 	"evaluator"
-	"fmt"
 	"io"
 	"lexer"
 	"object"
+	"os"
 	"parser"
+	"sort"
+	"strings"
+	"token"
 )
 
 // PROMPT prompt symbol
 const PROMPT = ">> "
 
+// CONTINUATION prompt symbol shown while the input is incomplete
+const CONTINUATION = ".. "
+
+// Session read-eval-print loop over configurable streams. Create it with
+// NewSession, adjust the fields, then call Run
+type Session struct {
+	In io.Reader
+	// Out receives values and meta-command output
+	Out io.Writer
+	// Err receives parse errors, runtime errors and failed meta-commands
+	Err io.Writer
+
+	Prompt             string
+	ContinuationPrompt string
+	// Banner is written to Out before the first prompt in interactive mode
+	Banner string
+
+	// Env holds bindings visible to the session, such as host-provided
+	// values. Input is evaluated in an environment enclosed by Env, so :reset
+	// discards what the user bound and keeps Env
+	Env *object.Environment
+	// Evaluator evaluates the input, set its SearchPath to configure imports
+	Evaluator *evaluator.Evaluator
+
+	// Interactive shows the banner and prompts, and edits lines when In and
+	// Out are terminals. NewSession enables it when In is a terminal
+	Interactive bool
+
+	env *object.Environment
+}
+
+// NewSession create new Session instance reading in, with prompts and
+// interactive mode set up for in
+func NewSession(in io.Reader, out io.Writer, errOut io.Writer) *Session {
+	f, ok := in.(*os.File)
+
+	return &Session{
+		In:                 in,
+		Out:                out,
+		Err:                errOut,
+		Prompt:             PROMPT,
+		ContinuationPrompt: CONTINUATION,
+		Env:                object.NewEnvironment(),
+		Evaluator:          evaluator.New(),
+		Interactive:        ok && isTerminal(f.Fd()),
+	}
+}
+
 // Start input stream reading function
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	NewSession(in, out, out).Run()
+}
+
+// Run read and evaluate input until it is exhausted. It returns nil at the end
+// of input, or the error that stopped reading
+func (s *Session) Run() error {
+	s.env = object.NewEnclosedEnvironment(s.Env)
+
+	r := s.newLineReader()
+	defer r.close()
+
+	if s.Interactive && s.Banner != "" {
+		io.WriteString(s.Out, s.Banner)
+	}
+
+	var input []string
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		prompt := s.Prompt
+		if len(input) != 0 {
+			prompt = s.ContinuationPrompt
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
+		line, err := r.readLine(prompt)
+		if err == errInterrupt {
+			input = input[:0]
+			continue
+		}
+		if err != nil {
+			// evaluate whatever is pending so its errors are reported
+			if len(input) != 0 {
+				s.eval(strings.Join(input, "\n"))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		r.addHistory(line)
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(input) == 0 && strings.HasPrefix(line, ":") {
+			s.command(line)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		input = append(input, line)
+		src := strings.Join(input, "\n")
+		if isIncomplete(src) {
+			continue
 		}
+
+		input = input[:0]
+		s.eval(src)
 	}
 }
 
+// complete list the names starting with prefix that are bound in the
+// session, builtin or reserved
+func (s *Session) complete(prefix string) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, group := range [][]string{s.env.Names(), s.Env.Names(), evaluator.BuiltinNames(), token.Keywords()} {
+		for _, name := range group {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (s *Session) eval(src string) {
+	if evaluated := s.run(src); evaluated != nil {
+		s.print(evaluated)
+	}
+}
+
+// run parse and evaluate src in the session environment, reporting parse
+// errors itself. It returns nil if src could not be parsed
+func (s *Session) run(src string) object.Object {
+	program := s.parse(src)
+	if program == nil {
+		return nil
+	}
+
+	return s.Evaluator.Eval(program, s.env)
+}
+
+// parse src, printing its parse errors to Err. The program is nil if there
+// are errors
+func (s *Session) parse(src string) *ast.Program {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.Err, p.Errors())
+		return nil
+	}
+	return program
+}
+
+// print write obj to Out, or its traceback to Err if it is an error
+func (s *Session) print(obj object.Object) {
+	if err, ok := obj.(*object.Error); ok {
+		io.WriteString(s.Err, err.Traceback())
+		io.WriteString(s.Err, "\n")
+		return
+	}
+
+	io.WriteString(s.Out, obj.Inspect())
+	io.WriteString(s.Out, "\n")
+}
+
+// isIncomplete report whether src ends inside an unterminated string or with
+// unbalanced braces, brackets or parentheses, so more input is needed
+func isIncomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET, token.LPAREN:
+			depth++
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			depth--
+		case token.ILLEGAL:
+			if strings.HasPrefix(tok.Literal, "\"") {
+				return true
+			}
+		}
+	}
+
+	return depth > 0
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
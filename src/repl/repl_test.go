@@ -0,0 +1,164 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n a + b\n};", false},
+		{"[1, 2,", true},
+		{"add(1,\n", true},
+		{`let s = "hello`, true},
+		{"let s = \"hello\nworld\";", false},
+		{"}", false},
+		{`"{"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+add(1,
+    2)
+let s = "a
+b"; len(s)
+if (true) {
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := "3\n3\n\texpected next token to be }, got EOF instead\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5; let f = fn(a, b) { a };\n:env", "f: FUNCTION = fn(a, b)\nx: INTEGER = 5\n"},
+		{":tokens x + 1", "1:1\tIDENT\t\"x\"\n1:3\t+\t\"+\"\n1:5\tINT\t\"1\"\n1:6\tEOF\t\"\"\n"},
+		{":ast -a", "Program\n  Statements[0]: ExpressionStatement\n    Expression: PrefixExpression Operator=\"-\"\n      Right: Identifier Value=\"a\"\n"},
+		{`:type "a"`, "STRING\n"},
+		{`:type puts()`, "NULL\n"},
+		{`:type let x = 1;`, "let x = 1; has no value\n"},
+		{`:type 1 +`, "\tno prefix parse function for EOF found\n"},
+		{"let x = 5;\n:reset\n:env", ""},
+		{":nope", "unknown command :nope, type :help for a list\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	if err := ioutil.WriteFile(script, []byte("#!/usr/bin/env monkey\nlet x = 1 + 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader(":load "+script+"\nx"), &out)
+
+	if out.String() != "2\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "2\n", out.String())
+	}
+}
+
+func TestSessionStreams(t *testing.T) {
+	input := "let x = 1;\nx + y\nx +\n:nope\nx"
+
+	var out, errOut bytes.Buffer
+	s := NewSession(strings.NewReader(input), &out, &errOut)
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+
+	if out.String() != "1\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "1\n", out.String())
+	}
+
+	expectedErr := "Traceback (most recent call last):\n  line 1, column 5, in <main>\nERROR: identifier not found: y\n" +
+		"\tno prefix parse function for EOF found\n" +
+		"unknown command :nope, type :help for a list\n"
+	if errOut.String() != expectedErr {
+		t.Errorf("wrong error output. expected=%q, got=%q", expectedErr, errOut.String())
+	}
+}
+
+func TestSessionInteractive(t *testing.T) {
+	var out bytes.Buffer
+	s := NewSession(strings.NewReader("fn() {\n1 }()\n"), &out, &out)
+	s.Interactive = true
+	s.Banner = "hi\n"
+	s.Prompt = "$ "
+	s.ContinuationPrompt = "> "
+	s.Run()
+
+	expected := "hi\n$ > 1\n$ "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestSessionEnv(t *testing.T) {
+	var out bytes.Buffer
+	s := NewSession(strings.NewReader("let y = host + 1;\n:reset\nhost\n:env\ny"), &out, &out)
+	s.Env.Set("host", object.NewInteger(41))
+	s.Run()
+
+	expected := "41\nTraceback (most recent call last):\n  line 1, column 1, in <main>\nERROR: identifier not found: y\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestResetKeepsConfiguration(t *testing.T) {
+	var out bytes.Buffer
+	s := NewSession(strings.NewReader(":reset\nlet loop = fn() { loop() }; loop()"), &out, &out)
+	s.Evaluator.MaxSteps = 1000
+	s.Run()
+
+	if !strings.Contains(out.String(), "step limit of 1000 exceeded") {
+		t.Errorf("step limit lost by :reset. got=%q", out.String())
+	}
+}
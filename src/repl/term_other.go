@@ -0,0 +1,24 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package repl
+
+import "errors"
+
+// lineEditing terminals cannot be put into raw mode here, lines are read plain
+const lineEditing = false
+
+// isTerminal terminals cannot be detected here, so every input is assumed to
+// be one and keeps its prompts
+func isTerminal(fd uintptr) bool {
+	return true
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
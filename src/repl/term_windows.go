@@ -0,0 +1,27 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build windows
+// +build windows
+
+package repl
+
+import (
+	"errors"
+	"syscall"
+)
+
+// lineEditing the editor needs a raw terminal, consoles read plain lines
+const lineEditing = false
+
+// isTerminal report whether fd refers to a console
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package resolver works out, before a monkey program runs, where each of its
+// identifiers is bound.
+//
+// Parameters and let bindings inside functions, and the catch parameter and
+// let bindings of catch blocks, are locals: they get a slot in the frame of
+// the call or catch block. Let bindings in if, try and finally blocks belong
+// to the enclosing frame, as they do when evaluating. A reference to a local
+// records how many frames out its binding is and in which slot. Everything
+// else is a global, looked up by name when evaluating.
+//
+// A function may use a local bound after the function in the same frame,
+// since all let bindings of a frame are known before its code is resolved.
+// A local read before its let statement ran evaluates to what the name is
+// bound to outside of the frame, as it would without a slot.
+package resolver
+
+import (
+	"ast"
+	"fmt"
+	"token"
+)
+
+// Error an identifier that is bound nowhere
+type Error struct {
+	Token token.Token
+	Name  string
+}
+
+func (e *Error) Error() string {
+	return "identifier not found: " + e.Name
+}
+
+// Resolve annotate the identifiers under node, which is resolved as top-level
+// code. defined reports whether a global not bound by node exists, in the
+// environment node is evaluated in or among the builtins for instance. The
+// identifiers that are neither local, bound at the top level of node nor
+// defined are returned in source order
+func Resolve(node ast.Node, defined func(name string) bool) []*Error {
+	r := &resolver{globals: map[string]bool{}, defined: defined}
+	declareLets(node, func(ident *ast.Identifier) { r.globals[ident.Value] = true })
+
+	ast.Walk(r, node)
+
+	return r.errors
+}
+
+type frame struct {
+	outer  *frame
+	slots  map[string]int
+	locals *[]string
+}
+
+type resolver struct {
+	frame   *frame
+	globals map[string]bool
+	defined func(name string) bool
+	errors  []*Error
+}
+
+// declareLets call declare for the let bindings made directly in body, that
+// is not in nested functions or catch blocks
+func declareLets(body ast.Node, declare func(ident *ast.Identifier)) {
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		case *ast.LetStatement:
+			declare(node.Name)
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+}
+
+// openFrame start a frame holding params then the let bindings of body, with
+// their names collected in locals
+func (r *resolver) openFrame(params []*ast.Identifier, body ast.Node, locals *[]string) {
+	*locals = []string{}
+	f := &frame{outer: r.frame, slots: map[string]int{}, locals: locals}
+	r.frame = f
+
+	for _, param := range params {
+		r.declare(param)
+	}
+	declareLets(body, func(ident *ast.Identifier) { r.declare(ident) })
+}
+
+func (r *resolver) closeFrame() {
+	r.frame = r.frame.outer
+}
+
+// declare give ident a slot in the current frame, the one it already has if
+// the name is bound again
+func (r *resolver) declare(ident *ast.Identifier) {
+	slot, ok := r.frame.slots[ident.Value]
+	if !ok {
+		slot = len(*r.frame.locals)
+		r.frame.slots[ident.Value] = slot
+		*r.frame.locals = append(*r.frame.locals, ident.Value)
+	}
+	ident.Local, ident.Depth, ident.Slot = true, 0, slot
+}
+
+// bind annotate the name bound by a let statement or parameter
+func (r *resolver) bind(ident *ast.Identifier) {
+	if r.frame == nil {
+		ident.Local, ident.Depth, ident.Slot = false, 0, 0
+		return
+	}
+	ident.Local, ident.Depth, ident.Slot = true, 0, r.frame.slots[ident.Value]
+}
+
+// use annotate a reference to ident
+func (r *resolver) use(ident *ast.Identifier) {
+	depth := 0
+	for f := r.frame; f != nil; f = f.outer {
+		if slot, ok := f.slots[ident.Value]; ok {
+			ident.Local, ident.Depth, ident.Slot = true, depth, slot
+			return
+		}
+		depth++
+	}
+
+	ident.Local, ident.Depth, ident.Slot = false, 0, 0
+	if !r.globals[ident.Value] && (r.defined == nil || !r.defined(ident.Value)) {
+		r.errors = append(r.errors, &Error{Token: ident.Token, Name: ident.Value})
+	}
+}
+
+// Visit resolve node. Nodes that bind names or open frames walk their own
+// children, so that only references reach the *ast.Identifier case
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case nil:
+		return nil
+	case *ast.Identifier:
+		r.use(node)
+	case *ast.LetStatement:
+		if node.Value != nil {
+			ast.Walk(r, node.Value)
+		}
+		r.bind(node.Name)
+		return nil
+	case *ast.MemberExpression:
+		// the property is a name in the object, not a reference
+		ast.Walk(r, node.Object)
+		return nil
+	case *ast.FunctionLiteral:
+		r.openFrame(node.Parameters, node.Body, &node.Locals)
+		ast.Walk(r, node.Body)
+		r.closeFrame()
+		return nil
+	case *ast.TryExpression:
+		ast.Walk(r, node.Block)
+		if node.Catch != nil {
+			r.openFrame([]*ast.Identifier{node.Param}, node.Catch, &node.CatchLocals)
+			ast.Walk(r, node.Catch)
+			r.closeFrame()
+		}
+		if node.Finally != nil {
+			ast.Walk(r, node.Finally)
+		}
+		return nil
+	case *ast.Program, *ast.BlockStatement, *ast.ExpressionStatement, *ast.ReturnStatement,
+		*ast.ThrowStatement, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean,
+		*ast.PrefixExpression, *ast.InfixExpression, *ast.IfExpression, *ast.CallExpression,
+		*ast.ArrayLiteral, *ast.IndexExpression, *ast.HashLiteral, *ast.ImportExpression,
+		*ast.TypeAnnotation:
+		// nothing bound here, Walk goes on with the children
+	default:
+		panic(fmt.Sprintf("resolver: unexpected node type %T", node))
+	}
+	return r
+}
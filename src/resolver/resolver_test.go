@@ -0,0 +1,112 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package resolver
+
+import (
+	"ast"
+	"fmt"
+	"lexer"
+	"parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+// annotations list the identifiers of program with where they resolved to, as
+// name@depth:slot for locals and name@global for the others
+func annotations(program *ast.Program) string {
+	var out []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if ident.Local {
+				out = append(out, fmt.Sprintf("%s@%d:%d", ident.Value, ident.Depth, ident.Slot))
+			} else {
+				out = append(out, ident.Value+"@global")
+			}
+		}
+		return true
+	})
+	return strings.Join(out, " ")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x", "x@global x@global"},
+		{"fn(a, b) { a + b }", "a@0:0 b@0:1 a@0:0 b@0:1"},
+		{"fn(a) { let b = a; b }", "a@0:0 b@0:1 a@0:0 b@0:1"},
+		{"fn(a) { fn(b) { a + b } }", "a@0:0 b@0:0 a@1:0 b@0:0"},
+		{"fn(a) { let a = 1; a }", "a@0:0 a@0:0 a@0:0"},
+		{"fn() { if (true) { let y = 1; } y }", "y@0:0 y@0:0"},
+		{"fn() { let f = fn() { g() }; let g = fn() { 1 }; }", "f@0:0 g@1:1 g@0:1"},
+		{"let f = fn() { g() }; let g = fn() { 1 };", "f@global g@global g@global"},
+		{"fn(a) { try { 1 } catch (e) { let m = e; a + m } }", "a@0:0 e@0:0 m@0:1 e@0:0 a@1:0 m@0:1"},
+		{"let m = import \"lib\"; m.name", "m@global m@global name@global"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if errs := Resolve(program, nil); len(errs) != 0 {
+			t.Errorf("Resolve(%q) returned errors: %v", tt.input, errs)
+			continue
+		}
+
+		if got := annotations(program); got != tt.expected {
+			t.Errorf("Resolve(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLocals(t *testing.T) {
+	program := parse(t, "fn(a, b) { let c = 1; if (a) { let d = 2 }; try { 1 } catch (e) { let f = 3 } }")
+	Resolve(program, nil)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if got := strings.Join(fn.Locals, ","); got != "a,b,c,d" {
+		t.Errorf("fn.Locals wrong. expected=%q, got=%q", "a,b,c,d", got)
+	}
+
+	try := fn.Body.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	if got := strings.Join(try.CatchLocals, ","); got != "e,f" {
+		t.Errorf("try.CatchLocals wrong. expected=%q, got=%q", "e,f", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x", []string{"1:1: identifier not found: x"}},
+		{"puts(x); fn(a) { a + y }", []string{"1:6: identifier not found: x", "1:22: identifier not found: y"}},
+		{"try { 1 } catch (e) { 2 }; e", []string{"1:28: identifier not found: e"}},
+		{"fn() { let z = 1 }; z", []string{"1:21: identifier not found: z"}},
+		{"puts(1)", nil},
+	}
+
+	defined := func(name string) bool { return name == "puts" }
+
+	for _, tt := range tests {
+		var got []string
+		for _, err := range Resolve(parse(t, tt.input), defined) {
+			got = append(got, fmt.Sprintf("%d:%d: %s", err.Token.Line, err.Token.Column, err))
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("Resolve(%q) errors wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
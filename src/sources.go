@@ -0,0 +1,42 @@
+// Copyright (c) 2018 meritozh
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// eachSource call process with the source of each file of paths, or of
+// standard input, named <stdin>, when there are none. Files that cannot be
+// read are reported on stderr as errors of the command name, with exit code
+// exitUsage. The highest exit code is returned
+func eachSource(name string, paths []string, stdin io.Reader, stderr io.Writer, process func(path string, src []byte) int) int {
+	if len(paths) == 0 {
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey %s: %s\n", name, err)
+			return exitUsage
+		}
+		return process("<stdin>", src)
+	}
+
+	code := 0
+	for _, path := range paths {
+		c := exitUsage
+		if src, err := ioutil.ReadFile(path); err != nil {
+			fmt.Fprintf(stderr, "monkey %s: %s\n", name, err)
+		} else {
+			c = process(path, src)
+		}
+
+		if c > code {
+			code = c
+		}
+	}
+	return code
+}
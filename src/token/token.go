@@ -5,6 +5,8 @@
 
 package token
 
+import "sort"
+
 const (
 	// ILLEGAL illegal token
 	ILLEGAL = "ILLEGAL"
@@ -39,6 +41,8 @@ const (
 
 	// COMMA comma
 	COMMA = ","
+	// DOT member access
+	DOT = "."
 	// SEMICOLON semicolon
 	SEMICOLON = ";"
 
@@ -66,6 +70,19 @@ const (
 	ELSE = "ELSE"
 	// RETURN return keyword
 	RETURN = "RETURN"
+	// TRY try keyword
+	TRY = "TRY"
+	// CATCH catch keyword
+	CATCH = "CATCH"
+	// FINALLY finally keyword
+	FINALLY = "FINALLY"
+	// THROW throw keyword
+	THROW = "THROW"
+	// IMPORT import keyword
+	IMPORT = "IMPORT"
+
+	// COMMENT line comment, collected by the lexer rather than returned
+	COMMENT = "COMMENT"
 
 	// STRING string literal
 	STRING = "STRING"
@@ -75,6 +92,8 @@ const (
 	RBRACKET = "]"
 	// COLON colon symbol
 	COLON = ":"
+	// ARROW return type of a function
+	ARROW = "->"
 )
 
 // Type string alias
@@ -84,16 +103,24 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+	// Line and Column locate the first character of the token, 1-based
+	Line   int
+	Column int
 }
 
 var keywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"import":  IMPORT,
 }
 
 // LookupIdent look up current string token type
@@ -103,3 +130,13 @@ func LookupIdent(ident string) Type {
 	}
 	return IDENT
 }
+
+// Keywords list every reserved word, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}